@@ -23,6 +23,7 @@ package cmd
 import (
 	"fmt"
 	"github.com/deckarep/golang-set"
+	"github.com/mattn/go-isatty"
 
 	"github.com/spf13/cobra"
 	"github.com/wagoodman/bashful/pkg/config"
@@ -32,12 +33,15 @@ import (
 	"github.com/wagoodman/bashful/utils"
 	"io/ioutil"
 	"math/rand"
+	"os"
 	"strings"
 	"time"
 )
 
 // todo: put these in a cli struct instance instead, then most logic can be in the cli struct
-var tags, onlyTags string
+var tags, onlyTags, dryRunFormat, output, outputFile, junitReportPath, ui string
+var resume, dryRun bool
+var vars []string
 
 // runCmd represents the run command
 var runCmd = &cobra.Command{
@@ -51,8 +55,31 @@ var runCmd = &cobra.Command{
 			utils.ExitWithErrorMessage("Options 'tags' and 'only-tags' are mutually exclusive.")
 		}
 
+		if dryRunFormat != "text" && dryRunFormat != "json" {
+			utils.ExitWithErrorMessage("Option 'dry-run-format' must be either 'text' or 'json'.")
+		}
+
+		if output != "ui" && output != "json" {
+			utils.ExitWithErrorMessage("Option 'output' must be either 'ui' or 'json'.")
+		}
+
+		if ui != "" && ui != "plain" && ui != "vertical" && ui != "single-line" && ui != "interactive" {
+			utils.ExitWithErrorMessage("Option 'ui' must be either 'plain', 'vertical', 'single-line', or 'interactive'.")
+		}
+
+		// a cursor-driven display cannot be shown when stdout is not a terminal (e.g. piped or in a CI system)
+		if ui == "" && !isatty.IsTerminal(os.Stdout.Fd()) && !isatty.IsCygwinTerminal(os.Stdout.Fd()) {
+			ui = "plain"
+		}
+
 		cli := config.Cli{
-			YamlPath: args[0],
+			YamlPath:        args[0],
+			Resume:          resume,
+			Output:          output,
+			OutputFile:      outputFile,
+			JUnitReportPath: junitReportPath,
+			UI:              ui,
+			DryRun:          dryRun,
 		}
 
 		if len(args) > 1 {
@@ -74,6 +101,15 @@ var runCmd = &cobra.Command{
 			}
 		}
 
+		cli.Vars = make(map[string]string)
+		for _, value := range vars {
+			fields := strings.SplitN(value, "=", 2)
+			if len(fields) != 2 || fields[0] == "" {
+				utils.ExitWithErrorMessage("Option 'var' must be given as 'key=value'.")
+			}
+			cli.Vars[fields[0]] = fields[1]
+		}
+
 		// todo: make this a function for CLI (addTag or something)
 		cli.RunTagSet = mapset.NewSet()
 		for _, tag := range cli.RunTags {
@@ -83,7 +119,14 @@ var runCmd = &cobra.Command{
 		yamlString, err := ioutil.ReadFile(cli.YamlPath)
 		utils.CheckError(err, "Unable to read yaml config.")
 
-		fmt.Print("\033[?25l") // hide cursor
+		if dryRun {
+			DryRun(yamlString, cli, dryRunFormat == "json")
+			return
+		}
+
+		if cli.Output != "json" && cli.UI != "plain" {
+			fmt.Print("\033[?25l") // hide cursor
+		}
 		Run(yamlString, cli)
 
 	},
@@ -94,6 +137,14 @@ func init() {
 
 	runCmd.Flags().StringVar(&tags, "tags", "", "A comma delimited list of matching task tags. If a task's tag matches *or if it is not tagged* then it will be executed (also see --only-tags)")
 	runCmd.Flags().StringVar(&onlyTags, "only-tags", "", "A comma delimited list of matching task tags. A task will only be executed if it has a matching tag")
+	runCmd.Flags().BoolVar(&dryRun, "dry-run", false, "Show every task that would be run (along with any urls to download, sudo requirements, and the expected runtime) without running anything (tasks with a 'for-each-cmd' are shown unexpanded)")
+	runCmd.Flags().StringVar(&dryRunFormat, "dry-run-format", "text", "The format of the dry run plan: 'text' or 'json'")
+	runCmd.Flags().StringVar(&output, "output", "ui", "How task progress is shown: 'ui' (interactive terminal display) or 'json' (a json line per task event written to stdout, see --output-file)")
+	runCmd.Flags().StringVar(&outputFile, "output-file", "", "Write a json line per task event to the given file (with '--output json' this replaces writing to stdout)")
+	runCmd.Flags().StringVar(&ui, "ui", "", "How task progress is displayed: 'vertical', 'single-line', 'interactive' (like 'vertical', but tasks can be selected with the arrow keys and enter shows the output (last 5000 lines) of the selected task), or 'plain' (timestamped lines without any cursor movement). By default 'plain' is used when stdout is not a terminal, otherwise the 'single-line-display' config option decides")
+	runCmd.Flags().StringArrayVar(&vars, "var", nil, "Set a template variable as 'key=value' (may be given multiple times), overriding the same key in the 'vars' section (referenced in a task as '{{ .Vars.key }}')")
+	runCmd.Flags().StringVar(&junitReportPath, "junit-report-path", "", "Write a JUnit XML report of all tasks to the given file after execution (overrides the 'junit-report-path' config option)")
+	runCmd.Flags().BoolVar(&resume, "resume", false, "Resume the last run of the given yaml file, skipping all tasks that have already succeeded")
 }
 
 func Run(yamlString []byte, cli config.Cli) {
@@ -103,13 +154,36 @@ func Run(yamlString []byte, cli config.Cli) {
 		utils.ExitWithErrorMessage(err.Error())
 	}
 
-	if client.Config.Options.SingleLineDisplay {
+	if cli.OutputFile != "" {
+		outputFh, err := os.Create(cli.OutputFile)
+		utils.CheckError(err, "Unable to create output file.")
+		client.AddEventHandler(handler.NewJsonStream(outputFh))
+	}
+
+	if cli.Output == "json" {
+		// stdout is reserved for the json event stream
+		client.Config.Options.ShowFailureReport = false
+		if cli.OutputFile == "" {
+			client.AddEventHandler(handler.NewJsonStream(os.Stdout))
+		}
+	} else if cli.UI == "plain" {
+		client.AddEventHandler(handler.NewPlainUI(client.Config, os.Stdout))
+	} else if cli.UI == "interactive" {
+		client.AddEventHandler(handler.NewInteractiveUI(client.Config, client.Executor))
+	} else if cli.UI == "single-line" || (cli.UI == "" && client.Config.Options.SingleLineDisplay) {
 		client.AddEventHandler(handler.NewCompressedUI(client.Config))
 	} else {
 		client.AddEventHandler(handler.NewVerticalUI(client.Config))
 	}
 	client.AddEventHandler(handler.NewTaskLogger(client.Config))
 
+	if cli.JUnitReportPath != "" {
+		client.Config.Options.JUnitReportPath = cli.JUnitReportPath
+	}
+	if client.Config.Options.JUnitReportPath != "" {
+		client.AddEventHandler(handler.NewJUnitReport(client.Config.Options.JUnitReportPath))
+	}
+
 	rand.Seed(time.Now().UnixNano())
 
 	tagInfo := ""
@@ -122,7 +196,13 @@ func Run(yamlString []byte, cli config.Cli) {
 		tagInfo += strings.Join(cli.RunTags, ", ")
 	}
 
-	fmt.Println(utils.Bold("Running " + tagInfo))
+	if cli.Output != "json" {
+		banner := "Running " + tagInfo
+		if cli.UI != "plain" {
+			banner = utils.Bold(banner)
+		}
+		fmt.Println(banner)
+	}
 	log.LogToMain("Running "+tagInfo, log.StyleMajor)
 
 	failedTasksErr := client.Run()
@@ -134,3 +214,14 @@ func Run(yamlString []byte, cli config.Cli) {
 		utils.Exit(1)
 	}
 }
+
+func DryRun(yamlString []byte, cli config.Cli, asJson bool) {
+
+	client, err := runtime.NewClientFromYaml(yamlString, &cli)
+	if err != nil {
+		utils.ExitWithErrorMessage(err.Error())
+	}
+
+	err = client.DryRun(os.Stdout, asJson)
+	utils.CheckError(err, "Unable to show the dry run plan.")
+}
@@ -21,18 +21,32 @@
 package config
 
 import (
+	"bytes"
 	"fmt"
 	"github.com/deckarep/golang-set"
 	"github.com/spf13/afero"
 	"github.com/wagoodman/bashful/utils"
 	"gopkg.in/yaml.v2"
+	"io/ioutil"
 	"os"
 	"path"
+	"regexp"
+	"sort"
 	"strings"
+	"text/template"
 )
 
 var globalOptions *Options
 
+// missingKeyPattern matches the template execution error of a value that is not defined
+var missingKeyPattern = regexp.MustCompile(`map has no entry for key "([^"]*)"`)
+
+// varReferencePattern matches a reference to a var within a template (e.g. '.Vars.version')
+var varReferencePattern = regexp.MustCompile(`\.Vars\.([A-Za-z_][A-Za-z0-9_]*)`)
+
+// runtimeTemplateFields are the task fields that are rendered again while running (the only fields which may reference registered vars)
+var runtimeTemplateFields = map[string]bool{"name": true, "cmd": true, "cwd": true, "env": true}
+
 // NewConfig creates a application runtime config given the user task yaml and CLI options
 func NewConfig(yamlString []byte, options *Cli) (*Config, error) {
 	config := Config{}
@@ -49,31 +63,156 @@ func NewConfig(yamlString []byte, options *Cli) (*Config, error) {
 	config.DownloadCachePath = path.Join(config.CachePath, "downloads")
 	config.LogCachePath = path.Join(config.CachePath, "logs")
 	config.EtaCachePath = path.Join(config.CachePath, "eta")
+	config.FingerprintCachePath = path.Join(config.CachePath, "fingerprints")
+	config.RunStatePath = path.Join(config.CachePath, "run-state")
 
 	err := config.compile(yamlString)
 	return &config, err
 }
 
 func (config *Config) validate() error {
-	var err error
-	// ensure not too many nestings of parallel tasks has been configured
-	for _, taskConfig := range config.TaskConfigs {
-		for _, subTaskConfig := range taskConfig.ParallelTasks {
-			if len(subTaskConfig.ParallelTasks) > 0 {
-				return fmt.Errorf("nested parallel tasks not allowed (violated by name:'" + subTaskConfig.Name + "' cmd:'" + subTaskConfig.CmdString + "')")
+	for _, taskConfigs := range [][]TaskConfig{config.TaskConfigs, config.FinallyTaskConfigs, config.OnFailureTaskConfigs} {
+		for _, taskConfig := range taskConfigs {
+			if len(taskConfig.Steps) > 0 {
+				return fmt.Errorf("'steps' is only allowed on child tasks (violated by name:'%s')", taskConfig.Name)
 			}
-			err = subTaskConfig.validate()
+			err := taskConfig.validate()
 			if err != nil {
 				return err
 			}
+			err = validateChildTaskConfigs(taskConfig)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	// hooks are always run in the order given
+	for _, taskConfigs := range [][]TaskConfig{config.FinallyTaskConfigs, config.OnFailureTaskConfigs} {
+		for _, taskConfig := range taskConfigs {
+			if len(taskConfig.Needs) > 0 {
+				return fmt.Errorf("'needs' is not allowed on 'finally' or 'on-failure' tasks (violated by name:'%s' cmd:'%s')", taskConfig.Name, taskConfig.CmdString)
+			}
+		}
+	}
+	err := config.validatePools()
+	if err != nil {
+		return err
+	}
+	return config.validateDependencies()
+}
 
+// validatePools ensures that all pools have a positive size and that all tasks only use configured pools
+func (config *Config) validatePools() error {
+	names := make([]string, 0, len(config.Options.Pools))
+	for name := range config.Options.Pools {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if config.Options.Pools[name] < 1 {
+			return fmt.Errorf("pool '%s' misconfigured (the size must be at least 1)", name)
 		}
-		err = taskConfig.validate()
-		if err != nil {
+	}
+
+	var visit func(taskConfigs []TaskConfig) error
+	visit = func(taskConfigs []TaskConfig) error {
+		for _, taskConfig := range taskConfigs {
+			if _, ok := config.Options.Pools[taskConfig.Pool]; taskConfig.Pool != "" && !ok {
+				return fmt.Errorf("task '%s' misconfigured (unknown pool '%s')", taskConfig.Name, taskConfig.Pool)
+			}
+			for _, subTaskConfigs := range [][]TaskConfig{taskConfig.ParallelTasks, taskConfig.TaskConfigs, taskConfig.Steps} {
+				if err := visit(subTaskConfigs); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	}
+	for _, taskConfigs := range [][]TaskConfig{config.TaskConfigs, config.FinallyTaskConfigs, config.OnFailureTaskConfigs} {
+		if err := visit(taskConfigs); err != nil {
 			return err
 		}
 	}
-	return err
+	return nil
+}
+
+// validateDependencies ensures that all task ids are unique, that all 'needs' references exist, and that there are no dependency cycles
+func (config *Config) validateDependencies() error {
+	dependencies := make(map[string][]string)
+	for _, taskConfig := range config.TaskConfigs {
+		if taskConfig.Id == "" {
+			continue
+		}
+		if _, exists := dependencies[taskConfig.Id]; exists {
+			return fmt.Errorf("duplicate task id '%s'", taskConfig.Id)
+		}
+		dependencies[taskConfig.Id] = taskConfig.Needs
+	}
+
+	for _, taskConfig := range config.TaskConfigs {
+		for _, need := range taskConfig.Needs {
+			if _, exists := dependencies[need]; !exists {
+				return fmt.Errorf("task '%s' needs an unknown task id '%s'", taskConfig.Name, need)
+			}
+		}
+	}
+
+	// depth first search for any task that (indirectly) depends on itself
+	const (
+		unvisited = iota
+		visiting
+		visited
+	)
+	state := make(map[string]int)
+	var visit func(id string, path []string) error
+	visit = func(id string, path []string) error {
+		path = append(path, id)
+		switch state[id] {
+		case visiting:
+			return fmt.Errorf("dependency cycle detected (%s)", strings.Join(path, " -> "))
+		case visited:
+			return nil
+		}
+		state[id] = visiting
+		for _, need := range dependencies[id] {
+			if err := visit(need, path); err != nil {
+				return err
+			}
+		}
+		state[id] = visited
+		return nil
+	}
+
+	for _, taskConfig := range config.TaskConfigs {
+		if taskConfig.Id == "" {
+			continue
+		}
+		if err := visit(taskConfig.Id, nil); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// validateChildTaskConfigs validates all (nested) child tasks of the given task
+func validateChildTaskConfigs(taskConfig TaskConfig) error {
+	for _, subTaskConfigs := range [][]TaskConfig{taskConfig.ParallelTasks, taskConfig.TaskConfigs, taskConfig.Steps} {
+		for _, subTaskConfig := range subTaskConfigs {
+			if len(subTaskConfig.Needs) > 0 {
+				return fmt.Errorf("'needs' is only allowed on top-level tasks (violated by name:'%s' cmd:'%s')", subTaskConfig.Name, subTaskConfig.CmdString)
+			}
+			err := subTaskConfig.validate()
+			if err != nil {
+				return err
+			}
+			err = validateChildTaskConfigs(subTaskConfig)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
 }
 
 // replaceArguments replaces the command line arguments in the given string
@@ -86,6 +225,191 @@ func (config *Config) replaceArguments(source string) string {
 	return replaced
 }
 
+// compileVars overrides the 'vars' values with the cli values (each cli value is parsed as a yaml scalar, e.g. numbers and booleans)
+func (config *Config) compileVars() {
+	if config.Vars == nil {
+		config.Vars = make(map[string]interface{})
+	}
+	for key, value := range config.Cli.Vars {
+		var typed interface{}
+		err := yaml.Unmarshal([]byte(value), &typed)
+		switch typed.(type) {
+		case bool, int, float64:
+			if err == nil {
+				config.Vars[key] = typed
+				continue
+			}
+		}
+		config.Vars[key] = value
+	}
+}
+
+// compileEnv merges the given parent env vars, the contents of each env file (relative to the given cwd), and the given
+// env vars (rendered as templates) into a new set of env vars, where later values take precedence
+func (config *Config) compileEnv(parentEnv map[string]string, envFiles stringArray, env map[string]string, cwd string) (map[string]string, error) {
+	compiled := make(map[string]string, len(parentEnv)+len(env))
+	for key, value := range parentEnv {
+		compiled[key] = value
+	}
+
+	for _, envFile := range envFiles {
+		filePath, err := config.renderTemplate("env-file", config.replaceArguments(envFile))
+		if err != nil {
+			return nil, err
+		}
+		if !path.IsAbs(filePath) {
+			filePath = path.Join(cwd, filePath)
+		}
+		contents, err := ioutil.ReadFile(filePath)
+		if err != nil {
+			return nil, fmt.Errorf("unable to read env file: %v", err)
+		}
+		values, err := parseDotenv(contents)
+		if err != nil {
+			return nil, fmt.Errorf("invalid env file '%s': %v", filePath, err)
+		}
+		for key, value := range values {
+			compiled[key] = value
+		}
+	}
+
+	for key, value := range env {
+		rendered, err := config.renderTemplate("env", config.replaceArguments(value))
+		if err != nil {
+			return nil, err
+		}
+		compiled[key] = rendered
+	}
+	return compiled, nil
+}
+
+// renderTemplate renders the given value of a task field as a template with all 'vars' values (any undefined value is
+// an error). A field that references a registered var is only checked and is left to be rendered while running.
+func (config *Config) renderTemplate(field, source string) (string, error) {
+	if !strings.Contains(source, "{{") {
+		return source, nil
+	}
+
+	var registered []string
+	for _, match := range varReferencePattern.FindAllStringSubmatch(source, -1) {
+		if config.RegisteredVars[match[1]] {
+			registered = append(registered, match[1])
+		}
+	}
+	if len(registered) == 0 {
+		return executeTemplate(field, source, config.Vars)
+	}
+
+	if !runtimeTemplateFields[field] {
+		return "", fmt.Errorf("registered variable '%s' may only be used in 'name', 'cmd', 'cwd', or 'env' (used in '%s')", registered[0], field)
+	}
+
+	// the registered values are unknown until the registering task has run
+	vars := make(map[string]interface{}, len(config.Vars)+len(config.RegisteredVars))
+	for key, value := range config.Vars {
+		vars[key] = value
+	}
+	for name := range config.RegisteredVars {
+		vars[name] = ""
+	}
+	if _, err := executeTemplate(field, source, vars); err != nil {
+		return "", err
+	}
+	return source, nil
+}
+
+// RenderTaskTemplates renders the name, cmd, cwd, and env values of the given task (which may reference registered vars)
+// with the given vars, indicating if any field was rendered
+func RenderTaskTemplates(taskConfig *TaskConfig, vars map[string]interface{}) (bool, error) {
+	fields := []struct {
+		name  string
+		value *string
+	}{
+		{"name", &taskConfig.Name},
+		{"cmd", &taskConfig.CmdString},
+		{"cwd", &taskConfig.CwdString},
+	}
+
+	rendered := false
+	for _, field := range fields {
+		if !strings.Contains(*field.value, "{{") {
+			continue
+		}
+		value, err := executeTemplate(field.name, *field.value, vars)
+		if err != nil {
+			return false, err
+		}
+		*field.value = value
+		rendered = true
+	}
+
+	// the env may be shared with other tasks, so any rendered values are set on a copy
+	env := make(map[string]string, len(taskConfig.Env))
+	renderedEnv := false
+	for key, value := range taskConfig.Env {
+		if strings.Contains(value, "{{") {
+			var err error
+			value, err = executeTemplate("env", value, vars)
+			if err != nil {
+				return false, err
+			}
+			renderedEnv = true
+		}
+		env[key] = value
+	}
+	if renderedEnv {
+		taskConfig.Env = env
+	}
+	return rendered || renderedEnv, nil
+}
+
+// executeTemplate renders the given value of a task field as a template with the given vars (any undefined value is an error)
+func executeTemplate(field, source string, vars map[string]interface{}) (string, error) {
+	tpl, err := template.New(field).Option("missingkey=error").Parse(source)
+	if err != nil {
+		return "", fmt.Errorf("invalid template in '%s': %v", field, err)
+	}
+
+	var buffer bytes.Buffer
+	err = tpl.Execute(&buffer, templateData{Vars: vars})
+	if err != nil {
+		if match := missingKeyPattern.FindStringSubmatch(err.Error()); match != nil {
+			names := make([]string, 0, len(vars))
+			for name := range vars {
+				names = append(names, name)
+			}
+			if len(names) == 0 {
+				return "", fmt.Errorf("undefined variable '%s' in '%s' (no vars are defined)", match[1], field)
+			}
+			sort.Strings(names)
+			return "", fmt.Errorf("undefined variable '%s' in '%s' (defined vars: %s)", match[1], field, strings.Join(names, ", "))
+		}
+		return "", fmt.Errorf("unable to render '%s': %v", field, err)
+	}
+	return buffer.String(), nil
+}
+
+// compileRegisteredVars collects the name of every var registered by the given (nested) tasks
+func (config *Config) compileRegisteredVars(taskConfigs []TaskConfig) error {
+	for _, taskConfig := range taskConfigs {
+		if taskConfig.Register != "" {
+			if !dotenvKeyPattern.MatchString(taskConfig.Register) {
+				return fmt.Errorf("task '%s' misconfigured ('register' must be a valid env var name, got '%s')", taskConfig.Name, taskConfig.Register)
+			}
+			config.RegisteredVars[taskConfig.Register] = true
+			if taskConfig.RegisterReturnCode {
+				config.RegisteredVars[taskConfig.Register+"_RC"] = true
+			}
+		}
+		for _, subTaskConfigs := range [][]TaskConfig{taskConfig.ParallelTasks, taskConfig.TaskConfigs, taskConfig.Steps} {
+			if err := config.compileRegisteredVars(subTaskConfigs); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
 // compile parses the given user yaml and populates the config object based on the cli arguments
 func (config *Config) compile(yamlString []byte) error {
 	var err error
@@ -108,79 +432,107 @@ func (config *Config) compile(yamlString []byte) error {
 		return fmt.Errorf("yaml invalid: %v", err)
 	}
 
+	config.compileVars()
+	config.RegisteredVars = make(map[string]bool)
+	for _, taskConfigs := range [][]TaskConfig{config.TaskConfigs, config.FinallyTaskConfigs, config.OnFailureTaskConfigs} {
+		err = config.compileRegisteredVars(taskConfigs)
+		if err != nil {
+			return fmt.Errorf("yaml invalid: %v", err)
+		}
+	}
+	config.Options.Env, err = config.compileEnv(nil, config.Options.EnvFile, config.Options.Env, "")
+	if err != nil {
+		return fmt.Errorf("yaml invalid: %v", err)
+	}
+	for _, taskConfigs := range []*[]TaskConfig{&config.TaskConfigs, &config.FinallyTaskConfigs, &config.OnFailureTaskConfigs} {
+		*taskConfigs, err = config.compileTaskConfigs(*taskConfigs, nil, config.Options.Env)
+		if err != nil {
+			return fmt.Errorf("yaml invalid: %v", err)
+		}
+	}
+
+	// a failing hook should never prevent the remaining hooks from running
+	disableStopOnFailure(config.FinallyTaskConfigs)
+	disableStopOnFailure(config.OnFailureTaskConfigs)
+
+	// prune the set of tasks that will not run given the set of cli options
+	if len(config.Cli.RunTags) > 0 {
+		config.TaskConfigs = config.pruneTaskConfigs(config.TaskConfigs)
+	}
+	return nil
+}
+
+// disableStopOnFailure ensures that none of the given tasks (or nested child tasks) halt the run on failure
+func disableStopOnFailure(taskConfigs []TaskConfig) {
+	for index := range taskConfigs {
+		taskConfig := &taskConfigs[index]
+		taskConfig.StopOnFailure = false
+		disableStopOnFailure(taskConfig.ParallelTasks)
+		disableStopOnFailure(taskConfig.TaskConfigs)
+		disableStopOnFailure(taskConfig.Steps)
+	}
+}
+
+// pruneTaskConfigs removes all of the given tasks that do not match the cli tags. A task with (nested) child tasks
+// that match the cli tags is kept (with only the matching child tasks). The steps of a task are never pruned.
+func (config *Config) pruneTaskConfigs(taskConfigs []TaskConfig) []TaskConfig {
+	remaining := make([]TaskConfig, 0, len(taskConfigs))
+	for _, taskConfig := range taskConfigs {
+		taskConfig.ParallelTasks = config.pruneTaskConfigs(taskConfig.ParallelTasks)
+		taskConfig.TaskConfigs = config.pruneTaskConfigs(taskConfig.TaskConfigs)
+		subTasksWithActiveTag := len(taskConfig.ParallelTasks) > 0 || len(taskConfig.TaskConfigs) > 0
+
+		matchedTaskTags := config.Cli.RunTagSet.Intersect(taskConfig.TagSet)
+		if !subTasksWithActiveTag && len(matchedTaskTags.ToSlice()) == 0 && (len(taskConfig.Tags) > 0 || config.Cli.ExecuteOnlyMatchedTags) {
+			// this task does not have matching tags and there are no children with matching tags: prune this task
+			continue
+		}
+		remaining = append(remaining, taskConfig)
+	}
+	return remaining
+}
+
+// compileTaskConfigs duplicates tasks with for-each clauses, passes the given parent tags and env vars (along with the
+// tags and env vars of each task) on to all nested child tasks, and derives the 'on-failure' task definitions of the given tasks
+func (config *Config) compileTaskConfigs(taskConfigs []TaskConfig, parentTags stringArray, parentEnv map[string]string) ([]TaskConfig, error) {
+	compiled := make([]TaskConfig, 0, len(taskConfigs))
+
 	// duplicate tasks with for-each clauses
-	for i := 0; i < len(config.TaskConfigs); i++ {
-		taskConfig := &config.TaskConfigs[i]
-		newTaskConfigs := taskConfig.compile(config)
-		if len(newTaskConfigs) > 0 {
-			for _, newConfig := range newTaskConfigs {
-				// insert the copy after current index
-				config.TaskConfigs = append(config.TaskConfigs[:i], append([]TaskConfig{newConfig}, config.TaskConfigs[i:]...)...)
-				i++
-			}
-			// remove current index
-			config.TaskConfigs = append(config.TaskConfigs[:i], config.TaskConfigs[i+1:]...)
-			i--
-		}
-
-		for j := 0; j < len(taskConfig.ParallelTasks); j++ {
-			subTaskConfig := &taskConfig.ParallelTasks[j]
-			newSubTaskConfigs := subTaskConfig.compile(config)
-
-			if len(newSubTaskConfigs) > 0 {
-				// remove the index with the template taskConfig
-				taskConfig.ParallelTasks = append(taskConfig.ParallelTasks[:j], taskConfig.ParallelTasks[j+1:]...)
-				for _, newConfig := range newSubTaskConfigs {
-					// insert the copy after current index
-					taskConfig.ParallelTasks = append(taskConfig.ParallelTasks[:j], append([]TaskConfig{newConfig}, taskConfig.ParallelTasks[j:]...)...)
-					j++
-				}
-			}
+	for _, taskConfig := range taskConfigs {
+		replicas, err := taskConfig.compile(config)
+		if err != nil {
+			return nil, err
 		}
+		compiled = append(compiled, replicas...)
 	}
 
-	// child tasks should inherit parent Config tags
-	for index := range config.TaskConfigs {
-		taskConfig := &config.TaskConfigs[index]
+	for index := range compiled {
+		taskConfig := &compiled[index]
+
+		// child tasks should inherit parent Config tags
+		tags := make(stringArray, 0, len(taskConfig.Tags)+len(parentTags))
+		taskConfig.Tags = append(append(tags, taskConfig.Tags...), parentTags...)
 		taskConfig.TagSet = mapset.NewSet()
 		for _, tag := range taskConfig.Tags {
 			taskConfig.TagSet.Add(tag)
 		}
-		for subIndex := range taskConfig.ParallelTasks {
-			subTaskConfig := &taskConfig.ParallelTasks[subIndex]
-			subTaskConfig.Tags = append(subTaskConfig.Tags, taskConfig.Tags...)
-			subTaskConfig.TagSet = mapset.NewSet()
-			for _, tag := range subTaskConfig.Tags {
-				subTaskConfig.TagSet.Add(tag)
-			}
+
+		// child tasks should inherit parent env vars (the task values take precedence)
+		var err error
+		taskConfig.Env, err = config.compileEnv(parentEnv, taskConfig.EnvFile, taskConfig.Env, taskConfig.CwdString)
+		if err != nil {
+			return nil, fmt.Errorf("task '%s' misconfigured (%v)", taskConfig.Name, err)
 		}
-	}
 
-	// prune the set of tasks that will not run given the set of cli options
-	if len(config.Cli.RunTags) > 0 {
-		for i := 0; i < len(config.TaskConfigs); i++ {
-			taskConfig := &config.TaskConfigs[i]
-			subTasksWithActiveTag := false
-
-			for j := 0; j < len(taskConfig.ParallelTasks); j++ {
-				subTaskConfig := &taskConfig.ParallelTasks[j]
-				matchedTaskTags := config.Cli.RunTagSet.Intersect(subTaskConfig.TagSet)
-				if len(matchedTaskTags.ToSlice()) > 0 || (len(subTaskConfig.Tags) == 0 && !config.Cli.ExecuteOnlyMatchedTags) {
-					subTasksWithActiveTag = true
-					continue
-				}
-				// this particular subtask does not have a matching tag: prune this task
-				taskConfig.ParallelTasks = append(taskConfig.ParallelTasks[:j], taskConfig.ParallelTasks[j+1:]...)
-				j--
-			}
+		// each 'on-failure' command is run as a task of its own
+		taskConfig.compileOnFailure(config)
 
-			matchedTaskTags := config.Cli.RunTagSet.Intersect(taskConfig.TagSet)
-			if !subTasksWithActiveTag && len(matchedTaskTags.ToSlice()) == 0 && (len(taskConfig.Tags) > 0 || config.Cli.ExecuteOnlyMatchedTags) {
-				// this task does not have matching tags and there are no children with matching tags: prune this task
-				config.TaskConfigs = append(config.TaskConfigs[:i], config.TaskConfigs[i+1:]...)
-				i--
+		for _, subTaskConfigs := range []*[]TaskConfig{&taskConfig.ParallelTasks, &taskConfig.TaskConfigs, &taskConfig.Steps} {
+			*subTaskConfigs, err = config.compileTaskConfigs(*subTaskConfigs, taskConfig.Tags, taskConfig.Env)
+			if err != nil {
+				return nil, err
 			}
 		}
 	}
-	return nil
+	return compiled, nil
 }
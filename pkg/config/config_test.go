@@ -3,6 +3,11 @@ package config
 import (
 	"github.com/deckarep/golang-set"
 	"github.com/wagoodman/bashful/utils"
+	"io/ioutil"
+	"os"
+	"path"
+	"reflect"
+	"strings"
 	"testing"
 )
 
@@ -184,6 +189,304 @@ tasks:
 	utils.AssertTestCases(t, collection)
 }
 
+func Test_Compile_Matrix(t *testing.T) {
+	runYaml := []byte(`
+tasks:
+  - name: Testing
+    parallel-tasks:
+      - name: "Testing <os> with go <version>"
+        cmd: some-place/scripts/test.sh --os <os> --go <version>
+        tags: test-<os>
+        matrix:
+          os: [linux, darwin]
+          version: [1.10, 1.11]
+          exclude:
+            - os: darwin
+              version: 1.10
+          include:
+            - os: windows
+              version: 1.11
+  - cmd: some-place/scripts/lint.sh
+    matrix:
+      linter: [vet, lint]`)
+
+	config, err := NewConfig(runYaml, nil)
+	if err != nil {
+		t.Fatalf("expected no config error, got %+v", err)
+	}
+
+	actualLen := len(config.TaskConfigs[0].ParallelTasks)
+	if actualLen != 4 {
+		t.Errorf("expected 4 parallel tasks, got %d", actualLen)
+	}
+
+	var collection = utils.TestCollection{
+		Collection: utils.InterfaceSlice(config.TaskConfigs[0].ParallelTasks),
+		Cases: []utils.TestCase{
+			{Index: 0, ExpectedValue: "Testing linux with go 1.10", ActualName: "Name"},
+			{Index: 0, ExpectedValue: "some-place/scripts/test.sh --os linux --go 1.10", ActualName: "CmdString"},
+			{Index: 1, ExpectedValue: "Testing linux with go 1.11", ActualName: "Name"},
+			{Index: 2, ExpectedValue: "Testing darwin with go 1.11", ActualName: "Name"},
+			{Index: 3, ExpectedValue: "Testing windows with go 1.11", ActualName: "Name"},
+			{Index: 3, ExpectedValue: "some-place/scripts/test.sh --os windows --go 1.11", ActualName: "CmdString"},
+		},
+	}
+	utils.AssertTestCases(t, collection)
+
+	if !config.TaskConfigs[0].ParallelTasks[3].TagSet.Contains("test-windows") {
+		t.Errorf("expected the replica tag to be replaced, got %v", config.TaskConfigs[0].ParallelTasks[3].Tags)
+	}
+
+	// replicas without any placeholder in the name are named after the combination
+	collection = utils.TestCollection{
+		Collection: utils.InterfaceSlice(config.TaskConfigs[1:]),
+		Cases: []utils.TestCase{
+			{Index: 0, ExpectedValue: "some-place/scripts/lint.sh (linter=vet)", ActualName: "Name"},
+			{Index: 1, ExpectedValue: "some-place/scripts/lint.sh (linter=lint)", ActualName: "Name"},
+		},
+	}
+	utils.AssertTestCases(t, collection)
+}
+
+func Test_Compile_InvalidMatrix(t *testing.T) {
+	table := map[string][]byte{
+		"matrix with for-each": []byte(`
+tasks:
+  - cmd: ./test.sh <os> <replace>
+    for-each: [a, b]
+    matrix:
+      os: [linux]`),
+		"exclude of an unknown dimension": []byte(`
+tasks:
+  - cmd: ./test.sh <os>
+    matrix:
+      os: [linux, darwin]
+      exclude:
+        - arch: arm`),
+		"partial include": []byte(`
+tasks:
+  - cmd: ./test.sh <os> <arch>
+    matrix:
+      os: [linux]
+      arch: [amd64]
+      include:
+        - os: windows`),
+		"dimension without a list": []byte(`
+tasks:
+  - cmd: ./test.sh <os>
+    matrix:
+      os: linux`),
+	}
+
+	for name, runYaml := range table {
+		t.Logf("Running test case: %s", name)
+		_, err := NewConfig(runYaml, nil)
+		if err == nil {
+			t.Errorf("expected a config error, got none")
+		}
+	}
+}
+
+func Test_Compile_ForEachSource(t *testing.T) {
+	dir, err := ioutil.TempDir("", "bashful-for-each")
+	if err != nil {
+		t.Fatalf("could not create a temp dir: %+v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	for _, service := range []string{"api", "web"} {
+		os.MkdirAll(path.Join(dir, "services", service), 0755)
+	}
+	ioutil.WriteFile(path.Join(dir, "regions.txt"), []byte("us-east-1\n\n  eu-west-1  \n"), 0644)
+	ioutil.WriteFile(path.Join(dir, "versions.json"), []byte(`["1.10", 2, "latest"]`), 0644)
+	ioutil.WriteFile(path.Join(dir, "empty.txt"), []byte("\n"), 0644)
+
+	table := map[string]struct {
+		runYaml       string
+		expectedNames []string
+		expectedErr   bool
+	}{
+		"for-each-cmd": {
+			runYaml: `
+tasks:
+  - name: Deploying <replace>
+    cwd: ` + dir + `
+    cmd: ./deploy.sh <replace>
+    for-each-cmd: ls services/`,
+			expectedNames: []string{"Deploying api", "Deploying web"},
+		},
+		"for-each-file with lines": {
+			runYaml: `
+tasks:
+  - name: Group
+    parallel-tasks:
+      - name: Deploying to <replace>
+        cmd: ./deploy.sh <replace>
+        for-each-file: ` + path.Join(dir, "regions.txt"),
+			expectedNames: []string{"Deploying to us-east-1", "Deploying to eu-west-1"},
+		},
+		"for-each-file with a json list": {
+			runYaml: `
+tasks:
+  - name: Testing <replace>
+    cwd: ` + dir + `
+    cmd: ./test.sh <replace>
+    for-each-file: versions.json`,
+			expectedNames: []string{"Testing 1.10", "Testing 2", "Testing latest"},
+		},
+		"for-each-file without values": {
+			runYaml: `
+tasks:
+  - name: Testing <replace>
+    cmd: ./test.sh <replace>
+    for-each-file: ` + path.Join(dir, "empty.txt"),
+			expectedNames: []string{},
+		},
+		"failing for-each-cmd": {
+			runYaml: `
+tasks:
+  - cmd: ./deploy.sh <replace>
+    for-each-cmd: exit 1`,
+			expectedErr: true,
+		},
+		"missing for-each-file": {
+			runYaml: `
+tasks:
+  - cmd: ./deploy.sh <replace>
+    for-each-file: ` + path.Join(dir, "missing.txt"),
+			expectedErr: true,
+		},
+		"for-each with for-each-cmd": {
+			runYaml: `
+tasks:
+  - cmd: ./deploy.sh <replace>
+    for-each: [api]
+    for-each-cmd: ls services/`,
+			expectedErr: true,
+		},
+	}
+
+	for name, testCase := range table {
+		t.Logf("Running test case: %s", name)
+		config, err := NewConfig([]byte(testCase.runYaml), nil)
+		if testCase.expectedErr {
+			if err == nil {
+				t.Errorf("expected a config error, got none")
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("expected no config error, got %+v", err)
+			continue
+		}
+
+		taskConfigs := config.TaskConfigs
+		if len(taskConfigs) == 1 && len(taskConfigs[0].ParallelTasks) > 0 {
+			taskConfigs = taskConfigs[0].ParallelTasks
+		}
+		names := []string{}
+		for _, taskConfig := range taskConfigs {
+			names = append(names, taskConfig.Name)
+		}
+		if !reflect.DeepEqual(names, testCase.expectedNames) {
+			t.Errorf("expected tasks %v, got %v", testCase.expectedNames, names)
+		}
+	}
+}
+
+func Test_Compile_Env(t *testing.T) {
+	dir, err := ioutil.TempDir("", "bashful-env")
+	if err != nil {
+		t.Fatalf("could not create a temp dir: %+v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	ioutil.WriteFile(path.Join(dir, "global.env"), []byte("REGION=us-east-1\nLEVEL=global-file\n"), 0644)
+	ioutil.WriteFile(path.Join(dir, "task.env"), []byte("export LEVEL=task-file\nTOKEN='s3cr#t'\n"), 0644)
+
+	runYaml := []byte(`
+vars:
+  version: 1.2.0
+config:
+  env-file: ` + path.Join(dir, "global.env") + `
+  env:
+    LEVEL: global
+tasks:
+  - name: Deploying
+    env:
+      VERSION: "{{ .Vars.version }}"
+    parallel-tasks:
+      - cmd: ./deploy.sh <replace>
+        cwd: ` + dir + `
+        env-file: task.env
+        env:
+          TARGET: <replace>
+        for-each: [api]
+  - cmd: ./check.sh`)
+
+	config, err := NewConfig(runYaml, nil)
+	if err != nil {
+		t.Fatalf("expected no config error, got %+v", err)
+	}
+
+	expected := []map[string]string{
+		{"REGION": "us-east-1", "LEVEL": "global", "VERSION": "1.2.0"},
+		{"REGION": "us-east-1", "LEVEL": "task-file", "VERSION": "1.2.0", "TOKEN": "s3cr#t", "TARGET": "api"},
+		{"REGION": "us-east-1", "LEVEL": "global"},
+	}
+	actual := []map[string]string{config.TaskConfigs[0].Env, config.TaskConfigs[0].ParallelTasks[0].Env, config.TaskConfigs[1].Env}
+	for idx := range expected {
+		if !reflect.DeepEqual(actual[idx], expected[idx]) {
+			t.Errorf("expected env %v, got %v", expected[idx], actual[idx])
+		}
+	}
+}
+
+func Test_ParseDotenv(t *testing.T) {
+	table := map[string]struct {
+		contents    string
+		expectedEnv map[string]string
+		expectedErr bool
+	}{
+		"plain values": {
+			contents:    "# a comment\n\nA=1\nexport B = two words # trailing comment\nC=",
+			expectedEnv: map[string]string{"A": "1", "B": "two words", "C": ""},
+		},
+		"quoted values": {
+			contents:    "A='single $quoted # value'\nB=\"line one\\nline \\\"two\\\"\"",
+			expectedEnv: map[string]string{"A": "single $quoted # value", "B": "line one\nline \"two\""},
+		},
+		"missing separator": {
+			contents:    "A",
+			expectedErr: true,
+		},
+		"invalid key": {
+			contents:    "MY-KEY=1",
+			expectedErr: true,
+		},
+		"unterminated quote": {
+			contents:    "A=\"value",
+			expectedErr: true,
+		},
+	}
+
+	for name, testCase := range table {
+		t.Logf("Running test case: %s", name)
+		env, err := parseDotenv([]byte(testCase.contents))
+		if testCase.expectedErr {
+			if err == nil {
+				t.Errorf("expected a parse error, got none")
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("expected no parse error, got %+v", err)
+		} else if !reflect.DeepEqual(env, testCase.expectedEnv) {
+			t.Errorf("expected env %v, got %v", testCase.expectedEnv, env)
+		}
+	}
+}
+
 func Test_Compile_TagInheritance(t *testing.T) {
 	runYaml := []byte(`
 x-reference-data:
@@ -261,6 +564,369 @@ tasks:
 	}
 }
 
+func Test_Compile_NestedGroups(t *testing.T) {
+	runYaml := []byte(`
+tasks:
+  - name: build
+    tags: build
+    tasks:
+      - name: compile
+        cmd: ./compile.sh
+      - name: checks
+        tags: checks
+        parallel-tasks:
+          - name: "lint <replace>"
+            cmd: ./lint.sh <replace>
+            for-each: [api, web]
+          - name: tests
+            tasks:
+              - name: unit tests
+                cmd: ./test.sh unit
+                tags: unit
+  - name: deploy
+    cmd: ./deploy.sh
+    tags: deploy`)
+
+	config, err := NewConfig(runYaml, &Cli{RunTags: []string{"unit"}, RunTagSet: mapset.NewSetWith("unit")})
+	if err != nil {
+		t.Fatalf("expected no config error, got %+v", err)
+	}
+
+	if len(config.TaskConfigs) != 1 || len(config.TaskConfigs[0].TaskConfigs) != 1 {
+		t.Fatalf("expected only the group with the matching nested task to remain, got %+v", config.TaskConfigs)
+	}
+
+	checks := config.TaskConfigs[0].TaskConfigs[0]
+	if checks.Name != "checks" || len(checks.ParallelTasks) != 1 {
+		t.Fatalf("expected only the 'checks' group with a single parallel task, got %+v", checks)
+	}
+
+	unitTests := checks.ParallelTasks[0].TaskConfigs[0]
+	expectedTags := []string{"unit", "checks", "build"}
+	if len(unitTests.Tags) != len(expectedTags) {
+		t.Fatalf("expected tags %v, got %v", expectedTags, unitTests.Tags)
+	}
+	for idx, expectedTag := range expectedTags {
+		if unitTests.Tags[idx] != expectedTag {
+			t.Errorf("expected tag='%s', got '%s'", expectedTag, unitTests.Tags[idx])
+		}
+		if !unitTests.TagSet.Contains(expectedTag) {
+			t.Errorf("expected tag='%s' to be in the TagSet but was not", expectedTag)
+		}
+	}
+
+	config, err = NewConfig(runYaml, nil)
+	if err != nil {
+		t.Fatalf("expected no config error, got %+v", err)
+	}
+
+	var collection = utils.TestCollection{
+		Collection: utils.InterfaceSlice(config.TaskConfigs[0].TaskConfigs[1].ParallelTasks),
+		Cases: []utils.TestCase{
+			{Index: 0, ExpectedValue: "lint api", ActualName: "Name"},
+			{Index: 0, ExpectedValue: "./lint.sh api", ActualName: "CmdString"},
+			{Index: 1, ExpectedValue: "lint web", ActualName: "Name"},
+			{Index: 1, ExpectedValue: "./lint.sh web", ActualName: "CmdString"},
+			{Index: 2, ExpectedValue: "tests", ActualName: "Name"},
+		},
+	}
+	utils.AssertTestCases(t, collection)
+
+	_, err = NewConfig([]byte(`
+tasks:
+  - name: ambiguous
+    tasks:
+      - cmd: ./a.sh
+    parallel-tasks:
+      - cmd: ./b.sh`), nil)
+	if err == nil {
+		t.Errorf("expected a config error for a task with both 'tasks' and 'parallel-tasks', got none")
+	}
+}
+
+func Test_Compile_Steps(t *testing.T) {
+	table := map[string]struct {
+		runYaml     []byte
+		expectedErr bool
+	}{
+		"steps of a parallel task": {
+			expectedErr: false,
+			runYaml: []byte(`
+tasks:
+  - parallel-tasks:
+    - name: tool
+      steps:
+        - cmd: ./download.sh
+        - cmd: ./install.sh`),
+		},
+		"steps of a top-level task": {
+			expectedErr: true,
+			runYaml: []byte(`
+tasks:
+  - name: tool
+    steps:
+      - cmd: ./download.sh`),
+		},
+		"steps with a cmd": {
+			expectedErr: true,
+			runYaml: []byte(`
+tasks:
+  - parallel-tasks:
+    - cmd: ./build.sh
+      steps:
+        - cmd: ./download.sh`),
+		},
+		"step without a cmd": {
+			expectedErr: true,
+			runYaml: []byte(`
+tasks:
+  - parallel-tasks:
+    - name: tool
+      steps:
+        - name: download
+          parallel-tasks:
+            - cmd: ./download.sh`),
+		},
+	}
+
+	for name, testCase := range table {
+		t.Logf("Running test case: %s", name)
+		_, err := NewConfig(testCase.runYaml, nil)
+		if testCase.expectedErr && err == nil {
+			t.Errorf("expected a config error, got none")
+		} else if !testCase.expectedErr && err != nil {
+			t.Errorf("expected no config error, got %+v", err)
+		}
+	}
+}
+
+func Test_Compile_Vars(t *testing.T) {
+	runYaml := []byte(`
+vars:
+  version: 1.2.0
+  region: us-east-1
+  replicas: 1
+tasks:
+  - name: build {{ .Vars.version }}
+    cmd: ./build.sh --version {{ .Vars.version }}
+    cwd: /srv/{{ .Vars.region }}
+    tags: "build-{{ .Vars.region }}"
+  - cmd: ./scale.sh {{ .Vars.replicas }}{{ if .Vars.debug }} --debug{{ end }}`)
+
+	config, err := NewConfig(runYaml, &Cli{Vars: map[string]string{"region": "eu-west-1", "replicas": "3", "debug": "true"}})
+	if err != nil {
+		t.Fatalf("expected no config error, got %+v", err)
+	}
+
+	expected := []struct {
+		name, cmd, cwd string
+		tags           stringArray
+	}{
+		{"build 1.2.0", "./build.sh --version 1.2.0", "/srv/eu-west-1", stringArray{"build-eu-west-1"}},
+		{"./scale.sh 3 --debug", "./scale.sh 3 --debug", "", stringArray{}},
+	}
+	for idx, taskConfig := range config.TaskConfigs {
+		if taskConfig.Name != expected[idx].name {
+			t.Errorf("expected name %q, got %q", expected[idx].name, taskConfig.Name)
+		}
+		if taskConfig.CmdString != expected[idx].cmd {
+			t.Errorf("expected cmd %q, got %q", expected[idx].cmd, taskConfig.CmdString)
+		}
+		if taskConfig.CwdString != expected[idx].cwd {
+			t.Errorf("expected cwd %q, got %q", expected[idx].cwd, taskConfig.CwdString)
+		}
+		if !reflect.DeepEqual(taskConfig.Tags, expected[idx].tags) {
+			t.Errorf("expected tags %v, got %v", expected[idx].tags, taskConfig.Tags)
+		}
+	}
+
+	if config.Vars["replicas"] != 3 || config.Vars["debug"] != true {
+		t.Errorf("expected typed cli vars, got %#v", config.Vars)
+	}
+}
+
+func Test_Compile_UndefinedVar(t *testing.T) {
+	runYaml := []byte(`
+vars:
+  version: 1.2.0
+tasks:
+  - parallel-tasks:
+    - cmd: ./deploy.sh {{ .Vars.enviroment }}`)
+
+	_, err := NewConfig(runYaml, nil)
+	if err == nil {
+		t.Fatalf("expected a config error, got none")
+	}
+	if !strings.Contains(err.Error(), "undefined variable 'enviroment' in 'cmd' (defined vars: version)") {
+		t.Errorf("expected an undefined variable error, got %+v", err)
+	}
+}
+
+func Test_Compile_InvalidOutputPattern(t *testing.T) {
+	runYaml := []byte(`
+tasks:
+  - name: check
+    cmd: ./check.sh
+    expect-stdout: "passed ("`)
+
+	_, err := NewConfig(runYaml, nil)
+	if err == nil {
+		t.Fatalf("expected a config error, got none")
+	}
+	if !strings.Contains(err.Error(), "task 'check' misconfigured (invalid 'expect-stdout' regex") {
+		t.Errorf("expected an invalid regex error, got %+v", err)
+	}
+}
+
+func Test_Compile_ShowOutputLines(t *testing.T) {
+	runYaml := []byte(`
+config:
+  show-output-lines: 3
+tasks:
+  - name: build
+    cmd: make
+  - name: checks
+    parallel-tasks:
+      - name: lint
+        cmd: lint
+      - name: test
+        cmd: go test
+        show-output-lines: 0`)
+
+	config, err := NewConfig(runYaml, nil)
+	if err != nil {
+		t.Fatalf("expected no config error, got %+v", err)
+	}
+
+	expected := map[string]int{"build": 3, "lint": 3, "test": 0}
+	for _, taskConfig := range []TaskConfig{config.TaskConfigs[0], config.TaskConfigs[1].ParallelTasks[0], config.TaskConfigs[1].ParallelTasks[1]} {
+		if taskConfig.ShowOutputLines != expected[taskConfig.Name] {
+			t.Errorf("expected task '%s' show-output-lines=%d, got %d", taskConfig.Name, expected[taskConfig.Name], taskConfig.ShowOutputLines)
+		}
+	}
+}
+
+func Test_Compile_Pools(t *testing.T) {
+	table := map[string]struct {
+		runYaml       string
+		expectedError string
+	}{
+		"configured pool": {runYaml: `
+config:
+  pools: {db: 1}
+tasks:
+  - parallel-tasks:
+    - cmd: ./migrate.sh
+      pool: db`},
+		"unknown pool": {runYaml: `
+config:
+  pools: {db: 1}
+tasks:
+  - name: migrate
+    cmd: ./migrate.sh
+    pool: database`, expectedError: "task 'migrate' misconfigured (unknown pool 'database')"},
+		"unknown child pool": {runYaml: `
+tasks:
+  - parallel-tasks:
+    - name: migrate
+      cmd: ./migrate.sh
+      pool: db`, expectedError: "task 'migrate' misconfigured (unknown pool 'db')"},
+		"empty pool": {runYaml: `
+config:
+  pools: {db: 0}
+tasks:
+  - cmd: ./migrate.sh`, expectedError: "pool 'db' misconfigured (the size must be at least 1)"},
+	}
+
+	for name, testCase := range table {
+		t.Logf("Running test case: %s", name)
+		_, err := NewConfig([]byte(testCase.runYaml), nil)
+		if testCase.expectedError == "" {
+			if err != nil {
+				t.Errorf("   expected no error, got %+v", err)
+			}
+			continue
+		}
+		if err == nil || !strings.Contains(err.Error(), testCase.expectedError) {
+			t.Errorf("   expected error '%s', got %+v", testCase.expectedError, err)
+		}
+	}
+}
+
+func Test_Compile_RegisteredVars(t *testing.T) {
+	table := map[string]struct {
+		runYaml     string
+		expectedCmd string
+		expectedErr bool
+	}{
+		"registered var in a cmd": {
+			runYaml: `
+vars:
+  app: api
+tasks:
+  - cmd: git describe --tags
+    register: VERSION
+  - cmd: ./deploy.sh {{ .Vars.app }} {{ .Vars.VERSION }}`,
+			expectedCmd: "./deploy.sh {{ .Vars.app }} {{ .Vars.VERSION }}",
+		},
+		"registered return code in a nested cmd": {
+			runYaml: `
+tasks:
+  - parallel-tasks:
+    - cmd: ./check.sh
+      register: CHECK
+      register-return-code: true
+  - cmd: echo {{ .Vars.CHECK_RC }}`,
+			expectedCmd: "echo {{ .Vars.CHECK_RC }}",
+		},
+		"undefined var next to a registered var": {
+			runYaml: `
+tasks:
+  - cmd: git describe --tags
+    register: VERSION
+  - cmd: ./deploy.sh {{ .Vars.app }} {{ .Vars.VERSION }}`,
+			expectedErr: true,
+		},
+		"registered var in tags": {
+			runYaml: `
+tasks:
+  - cmd: git describe --tags
+    register: VERSION
+  - cmd: ./deploy.sh
+    tags: "release-{{ .Vars.VERSION }}"`,
+			expectedErr: true,
+		},
+		"invalid register name": {
+			runYaml: `
+tasks:
+  - cmd: git describe --tags
+    register: app-version`,
+			expectedErr: true,
+		},
+	}
+
+	for name, testCase := range table {
+		t.Logf("Running test case: %s", name)
+		config, err := NewConfig([]byte(testCase.runYaml), nil)
+		if testCase.expectedErr {
+			if err == nil {
+				t.Errorf("expected a config error, got none")
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("expected no config error, got %+v", err)
+			continue
+		}
+
+		// a cmd that references a registered var is rendered while running
+		actualCmd := config.TaskConfigs[len(config.TaskConfigs)-1].CmdString
+		if actualCmd != testCase.expectedCmd {
+			t.Errorf("expected cmd %q, got %q", testCase.expectedCmd, actualCmd)
+		}
+	}
+}
+
 func Test_Compile_TagSelection(t *testing.T) {
 	runYaml := []byte(`
 x-reference-data:
@@ -410,3 +1076,152 @@ tasks:
 	}
 
 }
+
+func Test_Compile_Needs(t *testing.T) {
+	table := map[string]struct {
+		runYaml     []byte
+		expectedErr bool
+	}{
+		"valid dependencies": {
+			expectedErr: false,
+			runYaml: []byte(`
+tasks:
+  - id: build
+    cmd: ./build.sh
+  - id: test
+    needs: build
+    cmd: ./test.sh
+  - needs: [build, test]
+    cmd: ./deploy.sh`),
+		},
+		"unknown dependency": {
+			expectedErr: true,
+			runYaml: []byte(`
+tasks:
+  - id: build
+    cmd: ./build.sh
+  - needs: compile
+    cmd: ./test.sh`),
+		},
+		"duplicate id": {
+			expectedErr: true,
+			runYaml: []byte(`
+tasks:
+  - id: build
+    cmd: ./build.sh
+  - id: build
+    cmd: ./test.sh`),
+		},
+		"dependency cycle": {
+			expectedErr: true,
+			runYaml: []byte(`
+tasks:
+  - id: a
+    needs: c
+    cmd: ./a.sh
+  - id: b
+    needs: a
+    cmd: ./b.sh
+  - id: c
+    needs: b
+    cmd: ./c.sh`),
+		},
+		"needs on a parallel task": {
+			expectedErr: true,
+			runYaml: []byte(`
+tasks:
+  - id: build
+    cmd: ./build.sh
+  - parallel-tasks:
+    - cmd: ./test.sh
+      needs: build`),
+		},
+		"needs on a nested task": {
+			expectedErr: true,
+			runYaml: []byte(`
+tasks:
+  - id: build
+    cmd: ./build.sh
+  - tasks:
+    - parallel-tasks:
+      - cmd: ./test.sh
+        needs: build`),
+		},
+	}
+
+	for name, testCase := range table {
+		t.Logf("Running test case: %s", name)
+		_, err := NewConfig(testCase.runYaml, nil)
+		if testCase.expectedErr && err == nil {
+			t.Errorf("expected a config error, got none")
+		} else if !testCase.expectedErr && err != nil {
+			t.Errorf("expected no config error, got %+v", err)
+		}
+	}
+}
+
+func Test_Compile_Hooks(t *testing.T) {
+	runYaml := []byte(`
+config:
+  stop-on-failure: true
+tasks:
+  - name: Deploying <replace>
+    cmd: ./deploy.sh <replace>
+    cwd: /tmp
+    on-failure: ./rollback.sh <replace>
+    for-each: [app-1, app-2]
+on-failure:
+  - name: Notifying
+    cmd: ./notify.sh
+finally:
+  - name: Cleaning up
+    parallel-tasks:
+      - cmd: ./cleanup.sh <replace>
+        for-each: [app-1, app-2]`)
+
+	config, err := NewConfig(runYaml, nil)
+	if err != nil {
+		t.Errorf("expected no config error, got %+v", err)
+	}
+
+	actualLen := len(config.TaskConfigs)
+	if actualLen != 2 {
+		t.Errorf("expected 2 tasks, got %d", actualLen)
+	}
+
+	var collection = utils.TestCollection{
+		Collection: utils.InterfaceSlice(config.TaskConfigs[1].OnFailureTaskConfigs),
+		Cases: []utils.TestCase{
+			{Index: 0, ExpectedValue: "Deploying app-2: ./rollback.sh app-2", ActualName: "Name"},
+			{Index: 0, ExpectedValue: "./rollback.sh app-2", ActualName: "CmdString"},
+			{Index: 0, ExpectedValue: "/tmp", ActualName: "CwdString"},
+			{Index: 0, ExpectedValue: false, ActualName: "StopOnFailure"},
+		},
+	}
+	utils.AssertTestCases(t, collection)
+
+	collection = utils.TestCollection{
+		Collection: utils.InterfaceSlice(config.OnFailureTaskConfigs),
+		Cases: []utils.TestCase{
+			{Index: 0, ExpectedValue: "Notifying", ActualName: "Name"},
+			{Index: 0, ExpectedValue: false, ActualName: "StopOnFailure"},
+		},
+	}
+	utils.AssertTestCases(t, collection)
+
+	actualLen = len(config.FinallyTaskConfigs[0].ParallelTasks)
+	if actualLen != 2 {
+		t.Errorf("expected 2 parallel finally tasks, got %d", actualLen)
+	}
+
+	_, err = NewConfig([]byte(`
+tasks:
+  - id: build
+    cmd: ./build.sh
+finally:
+  - needs: build
+    cmd: ./cleanup.sh`), nil)
+	if err == nil {
+		t.Errorf("expected a config error for 'needs' on a finally task, got none")
+	}
+}
@@ -0,0 +1,102 @@
+// Copyright © 2018 Alex Goodman
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package config
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// dotenvKeyPattern matches a valid environment variable name
+var dotenvKeyPattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// parseDotenv parses 'KEY=value' lines (optionally prefixed with 'export'), ignoring blank lines and '#' comments. A
+// value may be single quoted (taken literally) or double quoted (with '\n', '\t', '\"', and '\\' escapes), otherwise
+// any trailing ' #' comment is removed.
+func parseDotenv(contents []byte) (map[string]string, error) {
+	env := make(map[string]string)
+	scanner := bufio.NewScanner(bytes.NewReader(contents))
+	for number := 1; scanner.Scan(); number++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		line = strings.TrimSpace(strings.TrimPrefix(line, "export "))
+
+		fields := strings.SplitN(line, "=", 2)
+		key := strings.TrimSpace(fields[0])
+		if len(fields) != 2 || !dotenvKeyPattern.MatchString(key) {
+			return nil, fmt.Errorf("line %d is not a 'KEY=value' pair", number)
+		}
+
+		value, err := parseDotenvValue(strings.TrimSpace(fields[1]))
+		if err != nil {
+			return nil, fmt.Errorf("line %d has an invalid value (%v)", number, err)
+		}
+		env[key] = value
+	}
+	return env, scanner.Err()
+}
+
+// parseDotenvValue removes any quotes (or trailing comment) from the given dotenv value
+func parseDotenvValue(value string) (string, error) {
+	if value == "" {
+		return "", nil
+	}
+
+	switch value[0] {
+	case '\'':
+		end := strings.Index(value[1:], "'")
+		if end < 0 {
+			return "", fmt.Errorf("missing closing quote")
+		}
+		return value[1 : end+1], nil
+	case '"':
+		var result strings.Builder
+		for idx := 1; idx < len(value); idx++ {
+			switch char := value[idx]; {
+			case char == '"':
+				return result.String(), nil
+			case char == '\\' && idx+1 < len(value):
+				idx++
+				switch value[idx] {
+				case 'n':
+					result.WriteByte('\n')
+				case 't':
+					result.WriteByte('\t')
+				default:
+					result.WriteByte(value[idx])
+				}
+			default:
+				result.WriteByte(char)
+			}
+		}
+		return "", fmt.Errorf("missing closing quote")
+	}
+
+	if idx := strings.Index(value, " #"); idx >= 0 {
+		value = value[:idx]
+	}
+	return strings.TrimSpace(value), nil
+}
@@ -0,0 +1,184 @@
+// Copyright © 2018 Alex Goodman
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package config
+
+import (
+	"fmt"
+	"gopkg.in/yaml.v2"
+	"strings"
+)
+
+// matrixValues is the list of values of a single dimension (decoded as given, e.g. '1.10' is not decoded as '1.1')
+type matrixValues struct {
+	values []string
+	isList bool
+}
+
+// UnmarshalYAML decodes a list of values, recording if the given value is not a list at all
+func (values *matrixValues) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	if err := unmarshal(&values.values); err == nil {
+		values.isList = true
+	}
+	return nil
+}
+
+// UnmarshalYAML parses a matrix from a map of dimension names to lists of values (along with optional 'exclude' and 'include' combinations)
+func (matrix *Matrix) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var combinations struct {
+		Exclude []map[string]string `yaml:"exclude"`
+		Include []map[string]string `yaml:"include"`
+	}
+	if err := unmarshal(&combinations); err != nil {
+		return err
+	}
+	matrix.Exclude = combinations.Exclude
+	matrix.Include = combinations.Include
+
+	var dimensions map[string]matrixValues
+	if err := unmarshal(&dimensions); err != nil {
+		return err
+	}
+
+	// a map slice keeps the dimensions in the order given
+	var order yaml.MapSlice
+	if err := unmarshal(&order); err != nil {
+		return err
+	}
+	for _, item := range order {
+		name := fmt.Sprint(item.Key)
+		if name == "exclude" || name == "include" {
+			continue
+		}
+		if !dimensions[name].isList {
+			return fmt.Errorf("matrix dimension '%s' must be a list of values", name)
+		}
+		matrix.Dimensions = append(matrix.Dimensions, MatrixDimension{Name: name, Values: dimensions[name].values})
+	}
+	return nil
+}
+
+// validate ensures every 'exclude' and 'include' combination only references known dimensions
+func (matrix *Matrix) validate() error {
+	known := make(map[string]bool)
+	for _, dimension := range matrix.Dimensions {
+		if len(dimension.Values) == 0 {
+			return fmt.Errorf("matrix dimension '%s' has no values", dimension.Name)
+		}
+		known[dimension.Name] = true
+	}
+	if len(known) == 0 {
+		return fmt.Errorf("matrix has no dimensions")
+	}
+
+	for _, combinations := range [][]map[string]string{matrix.Exclude, matrix.Include} {
+		for _, combination := range combinations {
+			for name := range combination {
+				if !known[name] {
+					return fmt.Errorf("matrix combination references unknown dimension '%s'", name)
+				}
+			}
+		}
+	}
+	for _, combination := range matrix.Include {
+		if len(combination) != len(known) {
+			return fmt.Errorf("matrix 'include' combination %s must give a value for every dimension", matrix.describe(combination))
+		}
+	}
+	return nil
+}
+
+// Combinations is the cartesian product of all dimension values (the first dimension varies slowest), without any
+// excluded combinations, followed by any included combinations that are not already present
+func (matrix *Matrix) Combinations() []map[string]string {
+	combinations := []map[string]string{{}}
+	for _, dimension := range matrix.Dimensions {
+		product := make([]map[string]string, 0, len(combinations)*len(dimension.Values))
+		for _, combination := range combinations {
+			for _, value := range dimension.Values {
+				next := make(map[string]string, len(combination)+1)
+				for name, existing := range combination {
+					next[name] = existing
+				}
+				next[dimension.Name] = value
+				product = append(product, next)
+			}
+		}
+		combinations = product
+	}
+
+	result := make([]map[string]string, 0, len(combinations)+len(matrix.Include))
+	for _, combination := range combinations {
+		excluded := false
+		for _, exclude := range matrix.Exclude {
+			if matchesCombination(combination, exclude) {
+				excluded = true
+				break
+			}
+		}
+		if !excluded {
+			result = append(result, combination)
+		}
+	}
+
+	for _, include := range matrix.Include {
+		present := false
+		for _, combination := range result {
+			if matchesCombination(combination, include) {
+				present = true
+				break
+			}
+		}
+		if !present {
+			result = append(result, include)
+		}
+	}
+	return result
+}
+
+// replacer replaces each '<dimension>' placeholder with the value of the given combination
+func (matrix *Matrix) replacer(combination map[string]string) *strings.Replacer {
+	pairs := make([]string, 0, 2*len(matrix.Dimensions))
+	for _, dimension := range matrix.Dimensions {
+		pairs = append(pairs, "<"+dimension.Name+">", combination[dimension.Name])
+	}
+	return strings.NewReplacer(pairs...)
+}
+
+// matchesCombination indicates if every value of the given (partial) pattern is found in the combination
+func matchesCombination(combination, pattern map[string]string) bool {
+	for name, value := range pattern {
+		if combination[name] != value {
+			return false
+		}
+	}
+	return true
+}
+
+// describe renders the given combination as 'name=value' pairs (in dimension order)
+func (matrix *Matrix) describe(combination map[string]string) string {
+	pairs := make([]string, 0, len(combination))
+	for _, dimension := range matrix.Dimensions {
+		if value, ok := combination[dimension.Name]; ok {
+			pairs = append(pairs, dimension.Name+"="+value)
+		}
+	}
+	return strings.Join(pairs, ", ")
+}
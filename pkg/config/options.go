@@ -28,13 +28,16 @@ func NewOptions() *Options {
 		ColorError:           160,
 		ColorPending:         22,
 		ColorRunning:         22,
+		ColorSkipped:         240,
 		ColorSuccess:         10,
 		EventDriven:          true,
 		ExecReplaceString:    "<exec>",
 		IgnoreFailure:        false,
+		KillGracePeriod:      5,
 		MaxParallelCmds:      4,
 		ReplicaReplaceString: "<replace>",
 		ShowFailureReport:    true,
+		ShowOutputLines:      0,
 		ShowSummaryErrors:    false,
 		ShowSummaryFooter:    true,
 		ShowSummarySteps:     true,
@@ -43,6 +46,7 @@ func NewOptions() *Options {
 		ShowTaskOutput:       true,
 		StopOnFailure:        true,
 		SingleLineDisplay:    false,
+		Timeout:              0,
 		UpdateInterval:       -1,
 	}
 }
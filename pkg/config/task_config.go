@@ -21,17 +21,37 @@
 package config
 
 import (
+	"bytes"
+	"encoding/json"
 	"fmt"
+	"github.com/deckarep/golang-set"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path"
+	"regexp"
 	"strings"
 )
 
+const (
+	// RetryBackoffConstant waits the same 'retry-delay' between each attempt
+	RetryBackoffConstant = "constant"
+
+	// RetryBackoffExponential doubles the 'retry-delay' after each attempt
+	RetryBackoffExponential = "exponential"
+)
+
 // NewTaskConfig creates a new TaskConfig populated with sane default values (derived from the global Options)
 func NewTaskConfig() (obj TaskConfig) {
 	obj.IgnoreFailure = globalOptions.IgnoreFailure
 	obj.StopOnFailure = globalOptions.StopOnFailure
 	obj.ShowTaskOutput = globalOptions.ShowTaskOutput
+	obj.ShowOutputLines = globalOptions.ShowOutputLines
 	obj.EventDriven = globalOptions.EventDriven
 	obj.CollapseOnCompletion = globalOptions.CollapseOnCompletion
+	obj.Timeout = globalOptions.Timeout
+	obj.KillGracePeriod = globalOptions.KillGracePeriod
+	obj.ExportEnv = globalOptions.ExportEnv
 
 	return obj
 }
@@ -67,7 +87,7 @@ func (a *stringArray) UnmarshalYAML(unmarshal func(interface{}) error) error {
 	return nil
 }
 
-func (taskConfig *TaskConfig) compile(config *Config) (tasks []TaskConfig) {
+func (taskConfig *TaskConfig) compile(config *Config) (tasks []TaskConfig, err error) {
 	taskConfig.CmdString = config.replaceArguments(taskConfig.CmdString)
 	if taskConfig.Name == "" {
 		taskConfig.Name = taskConfig.CmdString
@@ -75,36 +95,288 @@ func (taskConfig *TaskConfig) compile(config *Config) (tasks []TaskConfig) {
 		taskConfig.Name = config.replaceArguments(taskConfig.Name)
 	}
 
+	err = taskConfig.renderTemplates(config)
+	if err != nil {
+		return nil, err
+	}
+
+	err = taskConfig.compileForEachSource(config)
+	if err != nil {
+		return nil, err
+	}
+
+	// a dry run shows a task with a 'for-each-cmd' unexpanded (the command is not run)
+	if taskConfig.ForEachCmd != "" && config.Cli.DryRun {
+		return []TaskConfig{*taskConfig}, nil
+	}
+
 	if len(taskConfig.ForEach) > 0 {
 		for _, replicaValue := range taskConfig.ForEach {
-			// make replacements of select attributes on a copy of the Config
-			newConfig := *taskConfig
+			// insert the copy after current index
+			tasks = append(tasks, taskConfig.replicate(strings.NewReplacer(config.Options.ReplicaReplaceString, replicaValue)))
+		}
+	}
 
-			// ensure we don't re-compile a replica with more replica's of itself
-			newConfig.ForEach = make([]string, 0)
+	if taskConfig.Matrix != nil {
+		for _, combination := range taskConfig.Matrix.Combinations() {
+			newConfig := taskConfig.replicate(taskConfig.Matrix.replacer(combination))
 
-			if newConfig.Name == "" {
-				newConfig.Name = newConfig.CmdString
+			// replicas must be distinguishable even when the name has no placeholders
+			if newConfig.Name == taskConfig.Name {
+				newConfig.Name = fmt.Sprintf("%s (%s)", newConfig.Name, taskConfig.Matrix.describe(combination))
 			}
-			newConfig.Name = strings.Replace(newConfig.Name, config.Options.ReplicaReplaceString, replicaValue, -1)
-			newConfig.CmdString = strings.Replace(newConfig.CmdString, config.Options.ReplicaReplaceString, replicaValue, -1)
-			newConfig.URL = strings.Replace(newConfig.URL, config.Options.ReplicaReplaceString, replicaValue, -1)
+			tasks = append(tasks, newConfig)
+		}
+	}
+	// a replicated task without any values has no replicas at all
+	if len(taskConfig.ForEach) == 0 && taskConfig.ForEachCmd == "" && taskConfig.ForEachFile == "" && taskConfig.Matrix == nil {
+		tasks = []TaskConfig{*taskConfig}
+	}
+	return tasks, nil
+}
+
+// compileForEachSource sets the 'for-each' values from the output of the 'for-each-cmd' or the contents of the 'for-each-file' (both relative to the task 'cwd'). The 'for-each-cmd' is not run for a dry run.
+func (taskConfig *TaskConfig) compileForEachSource(config *Config) error {
+	var contents []byte
+	switch {
+	case taskConfig.ForEachCmd != "":
+		cmdString, err := config.renderTemplate("for-each-cmd", config.replaceArguments(taskConfig.ForEachCmd))
+		if err != nil {
+			return fmt.Errorf("task '%s' misconfigured (%v)", taskConfig.Name, err)
+		}
+		if config.Cli.DryRun {
+			taskConfig.ForEachCmd = cmdString
+			return nil
+		}
+
+		shell := os.Getenv("SHELL")
+		if len(shell) == 0 {
+			shell = "sh"
+		}
+		var stderr bytes.Buffer
+		cmd := exec.Command(shell, "-c", cmdString)
+		cmd.Dir = taskConfig.CwdString
+		cmd.Stderr = &stderr
+		contents, err = cmd.Output()
+		if err != nil {
+			return fmt.Errorf("task '%s' misconfigured ('for-each-cmd' failed: %v %s)", taskConfig.Name, err, strings.TrimSpace(stderr.String()))
+		}
+
+	case taskConfig.ForEachFile != "":
+		filePath, err := config.renderTemplate("for-each-file", config.replaceArguments(taskConfig.ForEachFile))
+		if err != nil {
+			return fmt.Errorf("task '%s' misconfigured (%v)", taskConfig.Name, err)
+		}
+		if !path.IsAbs(filePath) {
+			filePath = path.Join(taskConfig.CwdString, filePath)
+		}
+		contents, err = ioutil.ReadFile(filePath)
+		if err != nil {
+			return fmt.Errorf("task '%s' misconfigured (unable to read 'for-each-file': %v)", taskConfig.Name, err)
+		}
 
-			newConfig.Tags = make(stringArray, len(taskConfig.Tags))
-			for k := range taskConfig.Tags {
-				newConfig.Tags[k] = strings.Replace(taskConfig.Tags[k], config.Options.ReplicaReplaceString, replicaValue, -1)
+		// a json list gives a value per item
+		if trimmed := bytes.TrimSpace(contents); bytes.HasPrefix(trimmed, []byte("[")) {
+			values, err := jsonListValues(trimmed)
+			if err != nil {
+				return fmt.Errorf("task '%s' misconfigured (invalid json list in 'for-each-file': %v)", taskConfig.Name, err)
 			}
+			taskConfig.ForEach = values
+			return nil
+		}
 
-			// insert the copy after current index
-			tasks = append(tasks, newConfig)
+	default:
+		return nil
+	}
+
+	taskConfig.ForEach = make([]string, 0)
+	for _, line := range strings.Split(string(contents), "\n") {
+		if value := strings.TrimSpace(line); value != "" {
+			taskConfig.ForEach = append(taskConfig.ForEach, value)
+		}
+	}
+	return nil
+}
+
+// jsonListValues decodes a json list where each item is a value (strings as given, numbers/booleans/objects as json)
+func jsonListValues(contents []byte) ([]string, error) {
+	var items []json.RawMessage
+	if err := json.Unmarshal(contents, &items); err != nil {
+		return nil, err
+	}
+
+	values := make([]string, 0, len(items))
+	for _, item := range items {
+		var value string
+		if err := json.Unmarshal(item, &value); err != nil {
+			value = string(item)
+		}
+		values = append(values, value)
+	}
+	return values, nil
+}
+
+// replicate makes a copy of the task with the given replacements made to select attributes
+func (taskConfig *TaskConfig) replicate(replacer *strings.Replacer) TaskConfig {
+	newConfig := *taskConfig
+
+	// ensure we don't re-compile a replica with more replica's of itself
+	newConfig.ForEach = make([]string, 0)
+	newConfig.Matrix = nil
+	newConfig.ForEachCmd = ""
+	newConfig.ForEachFile = ""
+
+	if newConfig.Name == "" {
+		newConfig.Name = newConfig.CmdString
+	}
+	newConfig.Name = replacer.Replace(newConfig.Name)
+	newConfig.CmdString = replacer.Replace(newConfig.CmdString)
+	newConfig.URL = replacer.Replace(newConfig.URL)
+	newConfig.When = replacer.Replace(newConfig.When)
+	newConfig.Unless = replacer.Replace(newConfig.Unless)
+
+	newConfig.OnFailure = make(stringArray, len(taskConfig.OnFailure))
+	for k := range taskConfig.OnFailure {
+		newConfig.OnFailure[k] = replacer.Replace(taskConfig.OnFailure[k])
+	}
+
+	newConfig.Inputs = make(stringArray, len(taskConfig.Inputs))
+	for k := range taskConfig.Inputs {
+		newConfig.Inputs[k] = replacer.Replace(taskConfig.Inputs[k])
+	}
+
+	newConfig.Outputs = make(stringArray, len(taskConfig.Outputs))
+	for k := range taskConfig.Outputs {
+		newConfig.Outputs[k] = replacer.Replace(taskConfig.Outputs[k])
+	}
+
+	newConfig.Tags = make(stringArray, len(taskConfig.Tags))
+	for k := range taskConfig.Tags {
+		newConfig.Tags[k] = replacer.Replace(taskConfig.Tags[k])
+	}
+
+	newConfig.Env = make(map[string]string, len(taskConfig.Env))
+	for key, value := range taskConfig.Env {
+		newConfig.Env[key] = replacer.Replace(value)
+	}
+
+	return newConfig
+}
+
+// renderTemplates renders the name, cmd, cwd, url, and tags of the task as templates with all 'vars' values
+func (taskConfig *TaskConfig) renderTemplates(config *Config) error {
+	fields := []struct {
+		name  string
+		value *string
+	}{
+		{"cmd", &taskConfig.CmdString},
+		{"cwd", &taskConfig.CwdString},
+		{"url", &taskConfig.URL},
+		{"name", &taskConfig.Name},
+	}
+
+	// a name defaulted from the cmd is rendered as the cmd is
+	name := taskConfig.Name
+	defaultName := taskConfig.Name == taskConfig.CmdString
+	for _, field := range fields {
+		if field.value == &taskConfig.Name && defaultName {
+			taskConfig.Name = taskConfig.CmdString
+			continue
+		}
+		rendered, err := config.renderTemplate(field.name, *field.value)
+		if err != nil {
+			return fmt.Errorf("task '%s' misconfigured (%v)", name, err)
+		}
+		*field.value = rendered
+	}
+
+	// the tags may be shared with replicas of the task, which are rendered separately
+	tags := make(stringArray, len(taskConfig.Tags))
+	for idx, tag := range taskConfig.Tags {
+		rendered, err := config.renderTemplate("tags", tag)
+		if err != nil {
+			return fmt.Errorf("task '%s' misconfigured (%v)", name, err)
 		}
+		tags[idx] = rendered
+	}
+	taskConfig.Tags = tags
+	return nil
+}
+
+// compileOnFailure creates a task definition for each 'on-failure' command (run with the same cwd and sudo settings as the failed task)
+func (taskConfig *TaskConfig) compileOnFailure(config *Config) {
+	taskConfig.OnFailureTaskConfigs = nil
+	for _, cmdString := range taskConfig.OnFailure {
+		hookConfig := NewTaskConfig()
+		hookConfig.CmdString = config.replaceArguments(cmdString)
+		hookConfig.Name = fmt.Sprintf("%s: %s", taskConfig.Name, hookConfig.CmdString)
+		hookConfig.CwdString = taskConfig.CwdString
+		hookConfig.Sudo = taskConfig.Sudo
+		hookConfig.Env = taskConfig.Env
+		hookConfig.StopOnFailure = false
+		hookConfig.TagSet = mapset.NewSet()
+		taskConfig.OnFailureTaskConfigs = append(taskConfig.OnFailureTaskConfigs, hookConfig)
 	}
-	return tasks
+}
+
+// CompileOutputPattern compiles an 'expect-stdout' or 'fail-on-output' regex, where '^' and '$' match at line boundaries
+func CompileOutputPattern(pattern string) (*regexp.Regexp, error) {
+	return regexp.Compile("(?m)" + pattern)
 }
 
 func (taskConfig *TaskConfig) validate() error {
-	if taskConfig.CmdString == "" && len(taskConfig.ParallelTasks) == 0 && taskConfig.URL == "" {
-		return fmt.Errorf("task '" + taskConfig.Name + "' misconfigured (A configured task must have at least 'cmd', 'url', or 'parallel-tasks' configured)")
+	if taskConfig.CmdString == "" && len(taskConfig.ParallelTasks) == 0 && len(taskConfig.TaskConfigs) == 0 && len(taskConfig.Steps) == 0 && taskConfig.URL == "" {
+		return fmt.Errorf("task '%s' misconfigured (A configured task must have at least 'cmd', 'url', 'steps', 'tasks', or 'parallel-tasks' configured)", taskConfig.Name)
+	}
+	if len(taskConfig.ParallelTasks) > 0 && len(taskConfig.TaskConfigs) > 0 {
+		return fmt.Errorf("task '%s' misconfigured (only one of 'tasks' or 'parallel-tasks' may be configured)", taskConfig.Name)
+	}
+	if len(taskConfig.Steps) > 0 && (taskConfig.CmdString != "" || taskConfig.URL != "" || len(taskConfig.ParallelTasks) > 0 || len(taskConfig.TaskConfigs) > 0) {
+		return fmt.Errorf("task '%s' misconfigured ('steps' may not be combined with 'cmd', 'url', 'tasks', or 'parallel-tasks')", taskConfig.Name)
+	}
+	for _, step := range taskConfig.Steps {
+		if (step.CmdString == "" && step.URL == "") || len(step.ParallelTasks) > 0 || len(step.TaskConfigs) > 0 || len(step.Steps) > 0 {
+			return fmt.Errorf("task '%s' misconfigured (each step must have a 'cmd' or 'url' and no child tasks)", taskConfig.Name)
+		}
+	}
+	replicaSources := 0
+	for _, configured := range []bool{len(taskConfig.ForEach) > 0, taskConfig.ForEachCmd != "", taskConfig.ForEachFile != "", taskConfig.Matrix != nil} {
+		if configured {
+			replicaSources++
+		}
+	}
+	if replicaSources > 1 {
+		return fmt.Errorf("task '%s' misconfigured (only one of 'for-each', 'for-each-cmd', 'for-each-file', or 'matrix' may be configured)", taskConfig.Name)
+	}
+	if taskConfig.Matrix != nil {
+		if err := taskConfig.Matrix.validate(); err != nil {
+			return fmt.Errorf("task '%s' misconfigured (%v)", taskConfig.Name, err)
+		}
+	}
+	if taskConfig.Retries < 0 || taskConfig.RetryDelay < 0 {
+		return fmt.Errorf("task '%s' misconfigured ('retries' and 'retry-delay' must not be negative)", taskConfig.Name)
+	}
+	if taskConfig.Timeout < 0 || taskConfig.KillGracePeriod < 0 {
+		return fmt.Errorf("task '%s' misconfigured ('timeout' and 'kill-grace-period' must not be negative)", taskConfig.Name)
+	}
+	if taskConfig.ShowOutputLines < 0 {
+		return fmt.Errorf("task '%s' misconfigured ('show-output-lines' must not be negative)", taskConfig.Name)
+	}
+	for _, pattern := range taskConfig.ExportEnv {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("task '%s' misconfigured (invalid 'export-env' pattern '%s')", taskConfig.Name, pattern)
+		}
+	}
+	if _, err := CompileOutputPattern(taskConfig.ExpectStdout); err != nil {
+		return fmt.Errorf("task '%s' misconfigured (invalid 'expect-stdout' regex: %v)", taskConfig.Name, err)
+	}
+	if _, err := CompileOutputPattern(taskConfig.FailOnOutput); err != nil {
+		return fmt.Errorf("task '%s' misconfigured (invalid 'fail-on-output' regex: %v)", taskConfig.Name, err)
+	}
+	switch taskConfig.RetryBackoff {
+	case "", RetryBackoffConstant, RetryBackoffExponential:
+	default:
+		return fmt.Errorf("task '%s' misconfigured (unknown retry-backoff '%s', expected '%s' or '%s')", taskConfig.Name, taskConfig.RetryBackoff, RetryBackoffConstant, RetryBackoffExponential)
 	}
 	return nil
 }
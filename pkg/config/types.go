@@ -36,6 +36,18 @@ type Config struct {
 	// TaskConfigs is a list of task definitions and their metadata
 	TaskConfigs []TaskConfig `yaml:"tasks"`
 
+	// FinallyTaskConfigs is a list of task definitions that are always run after all other tasks (even when halted)
+	FinallyTaskConfigs []TaskConfig `yaml:"finally"`
+
+	// OnFailureTaskConfigs is a list of task definitions that are run after all other tasks only when any task has failed or the run was halted
+	OnFailureTaskConfigs []TaskConfig `yaml:"on-failure"`
+
+	// Vars is a set of (typed) values that task fields may reference as a template (e.g. '{{ .Vars.version }}'), overridden by any cli values
+	Vars map[string]interface{} `yaml:"vars"`
+
+	// RegisteredVars is the name of every var that is set by a task while running (see 'TaskConfig.Register')
+	RegisteredVars map[string]bool
+
 	// CachePath is the dir path to place any temporary files
 	CachePath string
 
@@ -48,6 +60,12 @@ type Config struct {
 	// DownloadCachePath is the dir path to place downloaded resources (from url references)
 	DownloadCachePath string
 
+	// FingerprintCachePath is the file path for per-task input fingerprints of previously succeeded tasks (derived from a tasks CmdString)
+	FingerprintCachePath string
+
+	// RunStatePath is the file path for the outcome of every task (and all captured env vars) of the last run (used to resume a failed run)
+	RunStatePath string
+
 	// TotalEtaSeconds is the calculated ETA given the tree of tasks to execute
 	TotalEtaSeconds float64
 }
@@ -59,6 +77,37 @@ type Cli struct {
 	RunTagSet              mapset.Set
 	ExecuteOnlyMatchedTags bool
 	Args                   []string
+	Resume                 bool
+	Output                 string
+	OutputFile             string
+	JUnitReportPath        string
+	UI                     string
+	Vars                   map[string]string
+	DryRun                 bool
+}
+
+// Matrix is a set of named dimensions (in the order given) with values that are combined into replicas of a task
+type Matrix struct {
+	// Dimensions is every named list of values
+	Dimensions []MatrixDimension
+
+	// Exclude is a list of (partial) combinations that no replica is made for
+	Exclude []map[string]string
+
+	// Include is a list of additional combinations (with a value for every dimension) that a replica is made for
+	Include []map[string]string
+}
+
+// MatrixDimension is a single named list of values of a matrix
+type MatrixDimension struct {
+	Name   string
+	Values []string
+}
+
+// templateData is the set of values available when rendering a task field as a template
+type templateData struct {
+	// Vars is every value given in the 'vars' section (or on the cli)
+	Vars map[string]interface{}
 }
 
 // Options is the set of values to be applied to all tasks or affect general behavior
@@ -84,21 +133,42 @@ type Options struct {
 	// ColorError is the color of the vertical progress bar when the task has failed (# in the 256 palett)
 	ColorError int `yaml:"error-status-color"`
 
+	// ColorSkipped is the color of the vertical progress bar when the task was not run (# in the 256 palett)
+	ColorSkipped int `yaml:"skipped-status-color"`
+
+	// Env is a set of environment variables given to every task (overriding any values from 'EnvFile')
+	Env map[string]string `yaml:"env"`
+
+	// EnvFile is one or more dotenv files (KEY=value lines) with environment variables given to every task
+	EnvFile stringArray `yaml:"env-file"`
+
 	// EventDriven indicates if the screen should be updated on any/all task stdout/stderr events or on a polling schedule
 	EventDriven bool `yaml:"event-driven"`
 
+	// ExportEnv is a list of glob patterns of the env var names that a task command may pass on to later tasks (all changed env vars by default, none when empty)
+	ExportEnv stringArray `yaml:"export-env"`
+
 	// ExecReplaceString is a char or short string that is replaced with the temporary executable path when using the 'url' task Config option
 	ExecReplaceString string `yaml:"exec-replace-pattern"`
 
 	// IgnoreFailure indicates when no errors should be registered (all task command non-zero return codes will be treated as a zero return code)
 	IgnoreFailure bool `yaml:"ignore-failure"`
 
+	// JUnitReportPath is the filepath to write a JUnit XML report of all tasks to after program execution (no report is written when empty)
+	JUnitReportPath string `yaml:"junit-report-path"`
+
+	// KillGracePeriod is the time in seconds to wait after asking a timed out task command to terminate (SIGTERM) before it is killed (SIGKILL)
+	KillGracePeriod float64 `yaml:"kill-grace-period"`
+
 	// LogPath is simply the filepath to write all main log entries
 	LogPath string `yaml:"log-path"`
 
 	// MaxParallelCmds indicates the most number of parallel commands that should be run at any one time
 	MaxParallelCmds int `yaml:"max-parallel-commands"`
 
+	// Pools is a set of named resource pools along with the most number of task commands using each pool that may run at any one time (across all tasks, see 'TaskConfig.Pool')
+	Pools map[string]int `yaml:"pools"`
+
 	// ReplicaReplaceString is a char or short string that is replaced with values given by a tasks "for-each" configuration
 	ReplicaReplaceString string `yaml:"replica-replace-pattern"`
 
@@ -123,12 +193,18 @@ type Options struct {
 	// ShowTaskOutput shows or hides a tasks command stdout/stderr while running
 	ShowTaskOutput bool `yaml:"show-task-output"`
 
+	// ShowOutputLines is the number of most recent stdout/stderr lines shown beneath each running task (0 shows only the latest line on the task line)
+	ShowOutputLines int `yaml:"show-output-lines"`
+
 	// StopOnFailure indicates to halt further program execution if a task command has a non-zero return code
 	StopOnFailure bool `yaml:"stop-on-failure"`
 
 	// SingleLineDisplay indicates to show all bashful output in a single line (instead of a line per task + a summary line)
 	SingleLineDisplay bool `yaml:"single-line"`
 
+	// Timeout is the default task timeout: the time in seconds that each task command may run before it is terminated and marked as timed out, unless the task sets its own 'timeout' (0 indicates no timeout). This does not limit the runtime of the whole run.
+	Timeout float64 `yaml:"timeout"`
+
 	// UpdateInterval is the time in seconds that the screen should be refreshed (only if EventDriven=false)
 	UpdateInterval float64 `yaml:"update-interval"`
 }
@@ -147,24 +223,96 @@ type TaskConfig struct {
 	// CollapseOnCompletion indicates when a task with child tasks should be "rolled up" into a single line after all tasks have been executed
 	CollapseOnCompletion bool `yaml:"collapse-on-completion"`
 
+	// Env is a set of environment variables given to the task command, which are merged with any inherited values at compile time (see 'Config.compileEnv')
+	Env map[string]string `yaml:"env"`
+
+	// EnvFile is one or more dotenv files (KEY=value lines, relative to the task 'cwd') with environment variables given to the task command
+	EnvFile stringArray `yaml:"env-file"`
+
 	// EventDriven indicates if the screen should be updated on any/all task stdout/stderr events or on a polling schedule
 	EventDriven bool `yaml:"event-driven"`
 
+	// ExpectStdout is a regex that the captured stdout of the task command must match for the task to succeed ('^' and '$' match at line boundaries)
+	ExpectStdout string `yaml:"expect-stdout"`
+
+	// ExportEnv is a list of glob patterns of the env var names that the command may pass on to later tasks (all changed env vars by default, none when empty)
+	ExportEnv stringArray `yaml:"export-env"`
+
+	// FailOnOutput is a regex that fails the task when it matches the captured stdout or stderr of the task command, regardless of the return code
+	FailOnOutput string `yaml:"fail-on-output"`
+
 	// ForEach is a list of strings that will be used to make replicas if the current task (tailored Name/CmdString replacements are handled via the 'ReplicaReplaceString' option)
 	ForEach []string `yaml:"for-each"`
 
+	// ForEachCmd is a shell command run at compile time where each (non-empty) line of stdout is used as a 'ForEach' value
+	ForEachCmd string `yaml:"for-each-cmd"`
+
+	// ForEachFile is a file read at compile time where each (non-empty) line, or each item of a json list, is used as a 'ForEach' value
+	ForEachFile string `yaml:"for-each-file"`
+
+	// Matrix is a set of named dimensions, where a replica of the current task is made for every combination of values (each '<dimension>' is replaced with the value of the combination)
+	Matrix *Matrix `yaml:"matrix"`
+
+	// Id is an optional unique identifier for a top-level task which may be referenced by other tasks (see 'Needs')
+	Id string `yaml:"id"`
+
 	// IgnoreFailure indicates when no errors should be registered (all task command non-zero return codes will be treated as a zero return code)
 	IgnoreFailure bool `yaml:"ignore-failure"`
 
+	// Inputs is a list of file globs that the task command depends on. The task is not run again when all inputs are unchanged since the last successful run (and all Outputs exist)
+	Inputs stringArray `yaml:"inputs"`
+
+	// KillGracePeriod is the time in seconds to wait after asking a timed out task command to terminate (SIGTERM) before it is killed (SIGKILL)
+	KillGracePeriod float64 `yaml:"kill-grace-period"`
+
 	// Md5 is the expected hash value after digesting a downloaded file from a Url (only used with TaskConfig.Url)
 	Md5 string `yaml:"md5"`
 
-	// ParallelTasks is a list of child tasks that should be run in concurrently with one another
+	// Needs is a list of task ids that must complete successfully before this task is started (when any task declares 'needs' the tasks are no longer run strictly in order)
+	Needs stringArray `yaml:"needs"`
+
+	// Register is the name of an env var and template var (e.g. '{{ .Vars.NAME }}') that the trimmed stdout of the command is stored in for all later tasks
+	Register string `yaml:"register"`
+
+	// RegisterReturnCode indicates that the return code of the command is stored in the '<Register>_RC' env var and template var as well
+	RegisterReturnCode bool `yaml:"register-return-code"`
+
+	// OnFailure is a list of commands to run (after all other tasks) when this task has failed
+	OnFailure stringArray `yaml:"on-failure"`
+
+	// OnFailureTaskConfigs is the task definitions derived from the OnFailure commands
+	OnFailureTaskConfigs []TaskConfig `yaml:"-"`
+
+	// Outputs is a list of file paths created by the task command, the task is always run when any output is missing
+	Outputs stringArray `yaml:"outputs"`
+
+	// ParallelTasks is a list of child tasks that should be run in concurrently with one another (each child task may be a group of child tasks itself)
 	ParallelTasks []TaskConfig `yaml:"parallel-tasks"`
 
+	// Pool is the name of the resource pool (see 'Options.Pools') that the task command, or every command of the child tasks, must wait on before running
+	Pool string `yaml:"pool"`
+
+	// Retries is the number of times a failed task command is re-run before the task is considered failed
+	Retries int `yaml:"retries"`
+
+	// RetryBackoff indicates how the delay between retries grows ('constant' or 'exponential')
+	RetryBackoff string `yaml:"retry-backoff"`
+
+	// RetryDelay is the time in seconds to wait before re-running a failed task command
+	RetryDelay float64 `yaml:"retry-delay"`
+
 	// ShowTaskOutput shows or hides a tasks command stdout/stderr while running
 	ShowTaskOutput bool `yaml:"show-output"`
 
+	// ShowOutputLines is the number of most recent stdout/stderr lines shown beneath the task while running (0 shows only the latest line on the task line)
+	ShowOutputLines int `yaml:"show-output-lines"`
+
+	// SuccessCodes is the list of task command return codes that are considered successful (only 0 when not provided)
+	SuccessCodes []int `yaml:"success-codes"`
+
+	// Steps is a list of commands of a child task that should be run one after another (shown as a single task, any remaining steps are skipped when a step fails)
+	Steps []TaskConfig `yaml:"steps"`
+
 	// StopOnFailure indicates to halt further program execution if a task command has a non-zero return code
 	StopOnFailure bool `yaml:"stop-on-failure"`
 
@@ -175,6 +323,20 @@ type TaskConfig struct {
 	Tags   stringArray `yaml:"tags"`
 	TagSet mapset.Set
 
+	// TaskConfigs is a list of child tasks that should be run one after another (each child task may be a group of child tasks itself)
+	TaskConfigs []TaskConfig `yaml:"tasks"`
+
+	// Timeout is the time in seconds that the task command may run before it is terminated and marked as timed out (0 indicates no timeout, defaults to the 'timeout' config option)
+	Timeout float64 `yaml:"timeout"`
+
+	// Unless is a condition which causes the task to be skipped when true (see 'When' for the expression format)
+	Unless string `yaml:"unless"`
+
 	// URL is the http/https link to a bash/executable resource
 	URL string `yaml:"url"`
+
+	// When is a condition which must be true for the task to be run, otherwise it is skipped. The expression is rendered
+	// as a template (with '.Env' and '.Tasks' values) and is either a boolean, a simple '==' or '!=' comparison, or a
+	// shell command that is true when it exits with a zero return code
+	When string `yaml:"when"`
 }
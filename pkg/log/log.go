@@ -76,7 +76,9 @@ func SetupLogging(logPath, cachePath string) {
 	}
 
 	removeDirContents(cachePath)
-	go mainLogger(logPath)
+	if logPath != "" {
+		go mainLogger(logPath)
+	}
 }
 
 // SingleLogger creats a separatly managed log (typically for an individual task to be later concatenated with the mainlog)
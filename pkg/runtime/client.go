@@ -54,15 +54,22 @@ func (client *Client) AddEventHandler(handler EventHandler) {
 }
 
 func (client *Client) Run() error {
+	// create the cache dirs if they do not already exist (a dry run only reads from the cache, see Plan)
+	if _, err := os.Stat(client.Config.CachePath); os.IsNotExist(err) {
+		os.Mkdir(client.Config.CachePath, 0755)
+	}
 
-	for _, task := range client.Executor.Tasks {
+	// cleanup tasks may require a password or downloads as well (see 'Plan')
+	for _, task := range client.Executor.allTasks() {
 		if task.requiresSudoPassword() {
 			sudoPassword = utils.GetSudoPasswd()
 			break
 		}
 	}
 
-	assetManager := NewDownloader(client.Executor.Tasks, client.Config.DownloadCachePath, client.Config.Options.MaxParallelCmds)
+	assetManager := NewDownloader(client.Executor.allTasks(), client.Config.DownloadCachePath, client.Config.Options.MaxParallelCmds)
+	// stdout is reserved for the json event stream, and plain output must not contain any cursor movement
+	assetManager.showProgress = client.Config.Cli.Output != "json" && client.Config.Cli.UI != "plain"
 	assetManager.Download()
 
 	client.Executor.estimateRuntime()
@@ -78,7 +85,13 @@ func (client *Client) Run() error {
 			buffer.WriteString(utils.Bold(utils.Red("• Failed task: ")) + utils.Bold(task.Config.Name) + "\n")
 			buffer.WriteString(utils.Red("  ├─ command: ") + task.Config.CmdString + "\n")
 			buffer.WriteString(utils.Red("  ├─ return code: ") + strconv.Itoa(task.Command.ReturnCode) + "\n")
-			buffer.WriteString(utils.Red("  └─ stderr: ") + task.Command.errorBuffer.String() + "\n")
+			if task.Command.FailureReason != "" {
+				buffer.WriteString(utils.Red("  ├─ reason: ") + task.Command.FailureReason + "\n")
+			}
+			if task.Config.Retries > 0 {
+				buffer.WriteString(utils.Red("  ├─ attempts: ") + strconv.Itoa(task.Command.Attempt) + "\n")
+			}
+			buffer.WriteString(utils.Red("  └─ stderr: ") + task.ErrorOutput() + "\n")
 
 		}
 		log.LogToMain(buffer.String(), "")
@@ -90,6 +103,19 @@ func (client *Client) Run() error {
 
 	}
 
+	if len(client.Executor.Statistics.Warnings) > 0 {
+		var buffer bytes.Buffer
+		buffer.WriteString(utils.Purple(" ...Some warnings were raised, see below for details.\n"))
+		for _, warning := range client.Executor.Statistics.Warnings {
+			buffer.WriteString(utils.Bold(utils.Purple("• Warning: ")) + warning + "\n")
+		}
+		log.LogToMain(buffer.String(), "")
+
+		if client.Config.Options.ShowFailureReport {
+			fmt.Print(buffer.String())
+		}
+	}
+
 	if len(client.Executor.Statistics.Failed) > 0 {
 		return fmt.Errorf("failed Tasks discovered")
 	}
@@ -98,7 +124,7 @@ func (client *Client) Run() error {
 }
 
 func (client *Client) Bundle(userYamlPath, outputPath string) error {
-	assetManager := NewDownloader(client.Executor.Tasks, client.Config.DownloadCachePath, client.Config.Options.MaxParallelCmds)
+	assetManager := NewDownloader(client.Executor.allTasks(), client.Config.DownloadCachePath, client.Config.Options.MaxParallelCmds)
 	assetManager.Download()
 
 	archivePath := "bundle.tar.gz"
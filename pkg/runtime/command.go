@@ -24,7 +24,7 @@ func newCommand(taskConfig config.TaskConfig) command {
 	if taskConfig.Sudo {
 		sudoCmd = "sudo -S "
 	}
-	cmd := exec.Command(shell, "-c", sudoCmd+taskConfig.CmdString+"; BASHFUL_RC=$?; env >&3; exit $BASHFUL_RC")
+	cmd := exec.Command(shell, "-c", sudoCmd+taskConfig.CmdString+"; BASHFUL_RC=$?; (env -0 2>/dev/null || env) >&3; exit $BASHFUL_RC")
 	cmd.Stdin = strings.NewReader(string(sudoPassword) + "\n")
 
 	// Set current working directory; default is empty
@@ -43,9 +43,26 @@ func newCommand(taskConfig config.TaskConfig) command {
 		Cmd:              cmd,
 		EstimatedRuntime: time.Duration(-1),
 		errorBuffer:      bytes.NewBufferString(""),
+		outputBuffer:     bytes.NewBufferString(""),
 	}
 }
 
+// reset prepares a fresh process for another attempt of the same command (a process can only be run once). The
+// estimated runtime is kept as is, since it may be read while the command is running.
+func (cmd *command) reset(taskConfig config.TaskConfig) {
+	fresh := newCommand(taskConfig)
+	cmd.Cmd = fresh.Cmd
+	cmd.EnvReadFile = fresh.EnvReadFile
+	cmd.Environment = fresh.Environment
+	cmd.StartTime = time.Time{}
+	cmd.StopTime = time.Time{}
+	cmd.ReturnCode = fresh.ReturnCode
+	cmd.TimedOut = false
+	cmd.FailureReason = ""
+	cmd.errorBuffer = fresh.errorBuffer
+	cmd.outputBuffer = fresh.outputBuffer
+}
+
 func (cmd *command) addEstimatedRuntime(duration time.Duration) {
 	cmd.EstimatedRuntime = duration
 }
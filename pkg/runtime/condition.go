@@ -0,0 +1,266 @@
+// Copyright © 2018 Alex Goodman
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package runtime
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"regexp"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// comparisonPattern matches simple "<operand> == <operand>" or "<operand> != <operand>" expressions (where each operand is a single word or a quoted string)
+var comparisonPattern = regexp.MustCompile(`^("[^"]*"|'[^']*'|[^\s"']*)\s*(==|!=)\s*("[^"]*"|'[^']*'|[^\s"']*)$`)
+
+// errConditionTimedOut indicates that a shell command condition ran longer than the task 'timeout'
+var errConditionTimedOut = errors.New("timed out")
+
+// conditionData is the set of values available when rendering a task 'when' or 'unless' expression
+type conditionData struct {
+	// Env is every environment variable available to the task command
+	Env map[string]string
+
+	// Tasks is the current status (e.g. "success", "error", "skipped") of every task with an id
+	Tasks map[string]string
+
+	// Vars is every value given in the 'vars' section (or on the cli)
+	Vars map[string]interface{}
+}
+
+// conditionData captures the environment and prior task outcomes used to evaluate task conditions
+func (executor *Executor) conditionData() conditionData {
+	data := conditionData{
+		Env:   make(map[string]string),
+		Tasks: make(map[string]string),
+		Vars:  executor.templateVars(),
+	}
+
+	for _, pair := range os.Environ() {
+		fields := strings.SplitN(pair, "=", 2)
+		if len(fields) == 2 {
+			data.Env[fields[0]] = fields[1]
+		}
+	}
+	for key, value := range executor.Environment {
+		data.Env[key] = value
+	}
+	for key, value := range executor.config.Options.Env {
+		data.Env[key] = value
+	}
+
+	for _, task := range executor.Tasks {
+		if task.Config.Id == "" {
+			continue
+		}
+		status := task.outcome()
+		// note: replicas share an id, any replica that has not succeeded takes precedence
+		if previous, exists := data.Tasks[task.Config.Id]; !exists || previous == StatusSuccess.String() {
+			data.Tasks[task.Config.Id] = status.String()
+		}
+	}
+	return data
+}
+
+// conditionResult is the outcome of the 'when' and 'unless' expressions of a task that were evaluated in the background
+type conditionResult struct {
+	task *Task
+
+	// skipReason indicates why the task should be skipped (empty when the task should be run)
+	skipReason string
+}
+
+// checkConditions evaluates the 'when' and 'unless' expressions of the given task, noting why the task should be skipped
+// (if at all). Expressions that are run as a shell command are evaluated in the background (limited by the task
+// 'timeout'), the task is not started until the result has been received (see onConditionResult).
+func (executor *Executor) checkConditions(task *Task) {
+	when, unless := task.Config.When, task.Config.Unless
+	if when == "" && unless == "" {
+		return
+	}
+
+	data := executor.conditionData()
+	if !requiresShell(when, data) && !requiresShell(unless, data) {
+		task.SkipReason = conditionSkipReason(context.Background(), when, unless, data)
+		return
+	}
+
+	ctx := context.Background()
+	cancel := func() {}
+	if task.Config.Timeout > 0 {
+		ctx, cancel = context.WithTimeout(ctx, time.Duration(task.Config.Timeout*float64(time.Second)))
+	}
+	ctx, cancelCondition := context.WithCancel(ctx)
+
+	task.lock.Lock()
+	task.conditionPending = true
+	task.cancelCondition = cancelCondition
+	task.lock.Unlock()
+
+	go func() {
+		defer cancel()
+		defer cancelCondition()
+		executor.conditions <- conditionResult{task: task, skipReason: conditionSkipReason(ctx, when, unless, data)}
+	}()
+}
+
+// onConditionResult notes the outcome of the conditions of a task evaluated in the background (unless the task has been
+// skipped in the meantime), allowing the task to be started
+func (executor *Executor) onConditionResult(result conditionResult) {
+	task := result.task
+
+	task.lock.Lock()
+	task.conditionPending = false
+	task.cancelCondition = nil
+	task.lock.Unlock()
+
+	// an interrupted task is skipped as such once started
+	if task.SkipReason == "" && !executor.interrupted {
+		task.SkipReason = result.skipReason
+	}
+}
+
+// conditionSkipReason evaluates the given 'when' and 'unless' expressions, returning why the task should be skipped
+// (an empty string indicates the task should be run)
+func conditionSkipReason(ctx context.Context, when, unless string, data conditionData) string {
+	if when != "" {
+		result, err := evaluateCondition(ctx, when, data)
+		if err == errConditionTimedOut {
+			return "'when' condition timed out"
+		}
+		if err != nil {
+			return fmt.Sprintf("invalid 'when' condition: %v", err)
+		}
+		if !result {
+			return "'when' condition is false"
+		}
+	}
+
+	if unless != "" {
+		result, err := evaluateCondition(ctx, unless, data)
+		if err == errConditionTimedOut {
+			return "'unless' condition timed out"
+		}
+		if err != nil {
+			return fmt.Sprintf("invalid 'unless' condition: %v", err)
+		}
+		if result {
+			return "'unless' condition is true"
+		}
+	}
+	return ""
+}
+
+// renderCondition renders the given expression as a template
+func renderCondition(expression string, data conditionData) (string, error) {
+	tmpl, err := template.New("condition").Option("missingkey=zero").Parse(expression)
+	if err != nil {
+		return "", err
+	}
+
+	var buffer bytes.Buffer
+	err = tmpl.Execute(&buffer, data)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(buffer.String()), nil
+}
+
+// evaluateLiteral evaluates the given rendered expression as a literal boolean or a simple comparison, returning false
+// as the second value if the expression is neither
+func evaluateLiteral(rendered string) (bool, bool) {
+	if value, err := strconv.ParseBool(rendered); err == nil {
+		return value, true
+	}
+
+	if matches := comparisonPattern.FindStringSubmatch(rendered); matches != nil {
+		equal := unquote(matches[1]) == unquote(matches[3])
+		if matches[2] == "==" {
+			return equal, true
+		}
+		return !equal, true
+	}
+	return false, false
+}
+
+// requiresShell indicates if the given expression is evaluated by running a shell command
+func requiresShell(expression string, data conditionData) bool {
+	if expression == "" {
+		return false
+	}
+	rendered, err := renderCondition(expression, data)
+	if err != nil {
+		return false
+	}
+	_, ok := evaluateLiteral(rendered)
+	return !ok
+}
+
+// evaluateCondition renders the given expression as a template and evaluates the result. The result may be a literal
+// boolean ("true"/"false"), a simple comparison ('"a" == "b"' or 'a != b'), or otherwise a shell command which is
+// considered true when it exits with a zero return code (the command is stopped once the given context is done).
+func evaluateCondition(ctx context.Context, expression string, data conditionData) (bool, error) {
+	rendered, err := renderCondition(expression, data)
+	if err != nil {
+		return false, err
+	}
+
+	if value, ok := evaluateLiteral(rendered); ok {
+		return value, nil
+	}
+
+	shell := os.Getenv("SHELL")
+	if len(shell) == 0 {
+		shell = "sh"
+	}
+	cmd := exec.CommandContext(ctx, shell, "-c", rendered)
+	for key, value := range data.Env {
+		cmd.Env = append(cmd.Env, fmt.Sprintf("%s=%s", key, value))
+	}
+	err = cmd.Run()
+	if ctx.Err() == context.DeadlineExceeded {
+		return false, errConditionTimedOut
+	}
+	if ctx.Err() != nil {
+		return false, ctx.Err()
+	}
+	if err != nil {
+		if _, ok := err.(*exec.ExitError); ok {
+			return false, nil
+		}
+		return false, err
+	}
+	return true, nil
+}
+
+// unquote removes a single pair of surrounding single or double quotes (if present)
+func unquote(value string) string {
+	if len(value) >= 2 && (value[0] == '"' || value[0] == '\'') && value[len(value)-1] == value[0] {
+		return value[1 : len(value)-1]
+	}
+	return value
+}
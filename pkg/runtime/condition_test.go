@@ -0,0 +1,45 @@
+package runtime
+
+import (
+	"context"
+	"testing"
+)
+
+func Test_evaluateCondition(t *testing.T) {
+	data := conditionData{
+		Env: map[string]string{
+			"CI":   "true",
+			"NAME": "bashful",
+		},
+		Tasks: map[string]string{
+			"build": "success",
+		},
+	}
+
+	table := map[string]struct {
+		expression     string
+		expectedResult bool
+	}{
+		"literal true":               {expression: "true", expectedResult: true},
+		"literal false":              {expression: "false", expectedResult: false},
+		"template boolean":           {expression: "{{ .Env.CI }}", expectedResult: true},
+		"quoted comparison":          {expression: `{{ .Env.CI }} == "true"`, expectedResult: true},
+		"single quoted comparison":   {expression: `'{{ .Env.NAME }}' != 'bashful'`, expectedResult: false},
+		"missing value comparison":   {expression: `{{ .Env.MISSING }} == ""`, expectedResult: true},
+		"task outcome comparison":    {expression: `{{ .Tasks.build }} == success`, expectedResult: true},
+		"shell command success":      {expression: `[ "$NAME" = "bashful" ]`, expectedResult: true},
+		"shell command failure":      {expression: `test -f /a/path/that/does/not/exist`, expectedResult: false},
+		"shell command with compare": {expression: `[ "{{ .Env.CI }}" == "false" ]`, expectedResult: false},
+	}
+
+	for name, testCase := range table {
+		t.Logf("Running test case: %s", name)
+		result, err := evaluateCondition(context.Background(), testCase.expression, data)
+		if err != nil {
+			t.Errorf("   unexpected error: %v", err)
+		}
+		if result != testCase.expectedResult {
+			t.Errorf("   expected result='%v', got '%v'", testCase.expectedResult, result)
+		}
+	}
+}
@@ -0,0 +1,161 @@
+// Copyright © 2018 Alex Goodman
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package runtime
+
+import (
+	"fmt"
+	"github.com/wagoodman/bashful/pkg/log"
+)
+
+const (
+	// killedReason is the failure reason of a Task command that was killed by the user
+	killedReason = "killed by user"
+
+	// userSkipReason is the skip reason of a Task that was skipped by the user
+	userSkipReason = "skipped by user"
+)
+
+// TaskAction is a change to a single Task requested by the user while the Executor is running
+type TaskAction int
+
+const (
+	// ActionKill stops the process group of a running Task command (the Task fails, but the run is not halted)
+	ActionKill TaskAction = iota
+
+	// ActionSkip marks a Task that has not been started yet as skipped
+	ActionSkip
+
+	// ActionRetry runs a failed Task command again
+	ActionRetry
+)
+
+// taskControl is a single user requested TaskAction for the given Task (or a function to call, see Invoke)
+type taskControl struct {
+	task   *Task
+	action TaskAction
+	invoke func()
+}
+
+// String returns a short description of the TaskAction
+func (action TaskAction) String() string {
+	switch action {
+	case ActionKill:
+		return "kill"
+	case ActionSkip:
+		return "skip"
+	case ActionRetry:
+		return "retry"
+	}
+	return "unknown"
+}
+
+// Control requests the given action to be applied to the given Task (or any child Task) by the Executor. Requests that
+// do not apply to the current state of the Task (e.g. killing a Task that is not running) are ignored.
+func (executor *Executor) Control(task *Task, action TaskAction) {
+	select {
+	case executor.controls <- taskControl{task: task, action: action}:
+	default:
+		log.LogToMain(fmt.Sprintf("unable to %s task '%s' (too many pending requests)", action, task.Config.Name), log.StyleError)
+	}
+}
+
+// Invoke requests the given function to be called by the Executor between events, where the state of all Tasks may be
+// read without racing the Executor (e.g. to draw a Task selected by the user). The function is called once the Executor
+// is running Tasks.
+func (executor *Executor) Invoke(fn func()) {
+	select {
+	case executor.controls <- taskControl{invoke: fn}:
+	default:
+		log.LogToMain("unable to handle user input (too many pending requests)", log.StyleError)
+	}
+}
+
+// onControl applies the given user requested action
+func (executor *Executor) onControl(control taskControl) {
+	if control.invoke != nil {
+		control.invoke()
+		return
+	}
+
+	task := control.task
+	log.LogToMain(fmt.Sprintf("user requested to %s task '%s'", control.action, task.Config.Name), log.StyleInfo)
+
+	switch control.action {
+	case ActionKill:
+		task.killCommand(killedReason)
+
+	case ActionSkip:
+		if task.Started {
+			return
+		}
+		task.SkipReason = userSkipReason
+
+	case ActionRetry:
+		if !task.Completed || (task.Status != StatusError && task.Status != StatusTimedOut) {
+			return
+		}
+		executor.requeue(task)
+	}
+}
+
+// requeue resets the given failed Task command to be run again (scheduling the top-level Task again if it has already finished)
+func (executor *Executor) requeue(task *Task) {
+	topTask := task.root()
+
+	executor.Statistics.Completed = removeTask(executor.Statistics.Completed, task)
+	executor.Statistics.Failed = removeTask(executor.Statistics.Failed, task)
+	executor.Statistics.Killed = removeTask(executor.Statistics.Killed, task)
+	for failedTask := task; failedTask != nil; failedTask = failedTask.parent {
+		failedTask.FailedChildren--
+	}
+
+	estimatedRuntime := task.Command.EstimatedRuntime
+	task.Command = newCommand(task.Config)
+	task.Command.addEstimatedRuntime(estimatedRuntime)
+	task.Started = false
+	task.Completed = false
+	task.killed = false
+	task.Status = StatusPending
+	for parent := task.parent; parent != nil; parent = parent.parent {
+		if parent.FailedChildren == 0 {
+			parent.Status = StatusRunning
+		}
+	}
+
+	if topTask.finished {
+		// the top-level task is registered with all handlers again once scheduled
+		topTask.finished = false
+		topTask.scheduled = false
+		return
+	}
+
+	executor.onEvent(TaskEvent{Task: task, Status: StatusPending, ReturnCode: -1})
+}
+
+// removeTask returns the given list of Tasks without the given Task
+func removeTask(tasks []*Task, task *Task) []*Task {
+	for idx, candidate := range tasks {
+		if candidate == task {
+			return append(tasks[:idx], tasks[idx+1:]...)
+		}
+	}
+	return tasks
+}
@@ -0,0 +1,156 @@
+package runtime
+
+import (
+	"fmt"
+	"github.com/wagoodman/bashful/pkg/config"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// controlTestHandler requests a TaskAction for a task (by name) once the given status is seen for that task
+type controlTestHandler struct {
+	executor *Executor
+	triggers map[string]TaskStatus
+	actions  map[string]TaskAction
+}
+
+func (handler *controlTestHandler) AddRuntimeData(data *TaskStatistics) {
+
+}
+
+func (handler *controlTestHandler) Register(task *Task) {
+	for _, subTask := range task.Children {
+		if handler.triggers[subTask.Config.Name] == StatusPending {
+			handler.trigger(subTask)
+		}
+	}
+}
+
+func (handler *controlTestHandler) Unregister(task *Task) {
+
+}
+
+func (handler *controlTestHandler) OnEvent(task *Task, e TaskEvent) {
+	if status, ok := handler.triggers[e.Task.Config.Name]; ok && status == e.Status {
+		handler.trigger(e.Task)
+	}
+}
+
+func (handler *controlTestHandler) Close() {
+
+}
+
+func (handler *controlTestHandler) trigger(task *Task) {
+	handler.executor.Control(task, handler.actions[task.Config.Name])
+	delete(handler.triggers, task.Config.Name)
+}
+
+func Test_Executor_Control(t *testing.T) {
+	signalExit(false)
+	tempDir, err := ioutil.TempDir("", "bashful-control")
+	if err != nil {
+		t.Fatalf("unable to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	runYaml := []byte(fmt.Sprintf(`
+config:
+  max-parallel-commands: 1
+  stop-on-failure: false
+tasks:
+  - name: parallel task
+    parallel-tasks:
+      - name: stuck task
+        cmd: sleep 30
+        # a task killed by the user always fails
+        ignore-failure: true
+      - name: flaky task
+        cmd: test -f %[1]s || (touch %[1]s; false)
+      - name: unwanted task
+        cmd: true
+`, filepath.Join(tempDir, "attempted")))
+
+	cfg, err := config.NewConfig(runYaml, nil)
+	if err != nil {
+		t.Fatalf("config creation failed: %v", err)
+	}
+	cfg.EtaCachePath = filepath.Join(tempDir, "eta")
+	cfg.RunStatePath = filepath.Join(tempDir, "run-state")
+	executor := newExecutor(cfg)
+	executor.addEventHandler(&controlTestHandler{
+		executor: executor,
+		triggers: map[string]TaskStatus{
+			"stuck task":    StatusRunning,
+			"flaky task":    StatusError,
+			"unwanted task": StatusPending,
+		},
+		actions: map[string]TaskAction{
+			"stuck task":    ActionKill,
+			"flaky task":    ActionRetry,
+			"unwanted task": ActionSkip,
+		},
+	})
+	executor.run()
+
+	expectedStatuses := map[string]TaskStatus{
+		"stuck task":    StatusError,
+		"flaky task":    StatusSuccess,
+		"unwanted task": StatusSkipped,
+	}
+	for _, task := range executor.Tasks[0].Children {
+		if task.Status != expectedStatuses[task.Config.Name] {
+			t.Errorf("expected task '%s' status=%v, got %v", task.Config.Name, expectedStatuses[task.Config.Name], task.Status)
+		}
+	}
+
+	stuckTask := executor.Tasks[0].Children[0]
+	if !stuckTask.Killed() || stuckTask.Command.FailureReason != killedReason {
+		t.Errorf("expected '%s' to be killed, got reason '%s'", stuckTask.Config.Name, stuckTask.Command.FailureReason)
+	}
+	if executor.Tasks[0].Children[2].SkipReason != userSkipReason {
+		t.Errorf("expected skip reason '%s', got '%s'", userSkipReason, executor.Tasks[0].Children[2].SkipReason)
+	}
+
+	stats := executor.Statistics
+	if len(stats.Failed) != 1 || len(stats.Killed) != 1 || len(stats.Skipped) != 1 || len(stats.Completed) != 3 {
+		t.Errorf("expected 1 failed, 1 killed, 1 skipped, and 3 completed tasks, got %d, %d, %d, and %d", len(stats.Failed), len(stats.Killed), len(stats.Skipped), len(stats.Completed))
+	}
+}
+
+func Test_Executor_Invoke(t *testing.T) {
+	signalExit(false)
+	tempDir, err := ioutil.TempDir("", "bashful-invoke")
+	if err != nil {
+		t.Fatalf("unable to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	runYaml := []byte(`
+tasks:
+  - name: slow task
+    cmd: sleep 0.2
+`)
+
+	cfg, err := config.NewConfig(runYaml, nil)
+	if err != nil {
+		t.Fatalf("config creation failed: %v", err)
+	}
+	cfg.EtaCachePath = filepath.Join(tempDir, "eta")
+	cfg.RunStatePath = filepath.Join(tempDir, "run-state")
+	executor := newExecutor(cfg)
+
+	var invoked bool
+	executor.Invoke(func() {
+		invoked = true
+		if executor.Tasks[0].Completed {
+			t.Errorf("expected the function to be called while the task is running")
+		}
+	})
+	executor.run()
+
+	if !invoked {
+		t.Errorf("expected the requested function to be called by the executor")
+	}
+}
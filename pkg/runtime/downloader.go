@@ -41,6 +41,10 @@ type downloader struct {
 	urlToRequest  map[string]*grab.Request
 	requestToTask map[*grab.Request][]*Task
 	urltoFilename map[string]string
+
+	// showProgress indicates that download progress bars are drawn on stdout (otherwise stdout is left untouched, and
+	// the progress is only written to stderr and the log without any cursor movement)
+	showProgress bool
 }
 
 func NewDownloader(tasks []*Task, downloadPath string, maxParallel int) *downloader {
@@ -54,12 +58,13 @@ func NewDownloader(tasks []*Task, downloadPath string, maxParallel int) *downloa
 		urlToRequest:  make(map[string]*grab.Request),
 		requestToTask: make(map[*grab.Request][]*Task),
 		urltoFilename: make(map[string]string),
+		showProgress:  true,
 	}
 
 	// gather all possible requests
 	for _, task := range tasks {
 		registry.AddRequest(task)
-		for _, subTask := range task.Children {
+		for _, subTask := range task.Descendants() {
 			registry.AddRequest(subTask)
 		}
 	}
@@ -77,6 +82,37 @@ func NewDownloader(tasks []*Task, downloadPath string, maxParallel int) *downloa
 }
 
 func (registry *downloader) monitorDownload(requests map[*grab.Request][]*Task, response *grab.Response, waiter *sync.WaitGroup) {
+	if registry.showProgress {
+		registry.showDownloadProgress(requests, response)
+	} else {
+		<-response.Done
+		message := fmt.Sprintf("Downloaded '%s' [%v]", response.Request.URL(), humanize.Bytes(uint64(response.BytesComplete())))
+		fmt.Fprintln(os.Stderr, message)
+		log.LogToMain(message, log.StyleInfo)
+	}
+
+	// rename file to match the last part of the url
+	expectedFilepath := registry.urltoFilename[response.Request.URL().String()]
+	if response.Filename != expectedFilepath {
+		err := os.Rename(response.Filename, expectedFilepath)
+		utils.CheckError(err, "Unable to rename downloaded asset: "+response.Filename)
+	}
+
+	// ensure the asset is executable
+	err := os.Chmod(expectedFilepath, 0755)
+	utils.CheckError(err, "Unable to make asset executable: "+expectedFilepath)
+
+	// update all Tasks using this asset to use the final filepath
+	for _, task := range registry.requestToTask[response.Request] {
+		task.UpdateExec(expectedFilepath)
+	}
+
+	waiter.Done()
+
+}
+
+// showDownloadProgress draws a progress bar for the given download until the download has completed
+func (registry *downloader) showDownloadProgress(requests map[*grab.Request][]*Task, response *grab.Response) {
 	bar := uiprogress.AddBar(100)
 	bar.AppendFunc(func(b *uiprogress.Bar) string {
 
@@ -130,25 +166,6 @@ Loop:
 			break Loop
 		}
 	}
-
-	// rename file to match the last part of the url
-	expectedFilepath := registry.urltoFilename[response.Request.URL().String()]
-	if response.Filename != expectedFilepath {
-		err := os.Rename(response.Filename, expectedFilepath)
-		utils.CheckError(err, "Unable to rename downloaded asset: "+response.Filename)
-	}
-
-	// ensure the asset is executable
-	err := os.Chmod(expectedFilepath, 0755)
-	utils.CheckError(err, "Unable to make asset executable: "+expectedFilepath)
-
-	// update all Tasks using this asset to use the final filepath
-	for _, task := range registry.requestToTask[response.Request] {
-		task.UpdateExec(expectedFilepath)
-	}
-
-	waiter.Done()
-
 }
 
 // AddRequest extracts all URLS configured for a given task (does not examine child Tasks) and queues them for download
@@ -202,16 +219,20 @@ func (registry *downloader) Download() {
 		return
 	}
 
-	fmt.Println(utils.Bold("Downloading referenced assets"))
 	log.LogToMain("Downloading referenced assets", log.StyleMajor)
-
-	uiprogress.Empty = ' '
-	uiprogress.Fill = '|'
-	uiprogress.Head = ' '
-	uiprogress.LeftEnd = '|'
-	uiprogress.RightEnd = '|'
-
-	uiprogress.Start()
+	if !registry.showProgress {
+		fmt.Fprintln(os.Stderr, "Downloading referenced assets")
+	} else {
+		fmt.Println(utils.Bold("Downloading referenced assets"))
+
+		uiprogress.Empty = ' '
+		uiprogress.Fill = '|'
+		uiprogress.Head = ' '
+		uiprogress.LeftEnd = '|'
+		uiprogress.RightEnd = '|'
+
+		uiprogress.Start()
+	}
 	respch := client.DoBatch(registry.maxParallel, allRequests...)
 	var waiter sync.WaitGroup
 	var responses []*grab.Response
@@ -223,7 +244,9 @@ func (registry *downloader) Download() {
 	}
 
 	waiter.Wait()
-	uiprogress.Stop()
+	if registry.showProgress {
+		uiprogress.Stop()
+	}
 
 	// verify no download errors
 	foundFailedAsset := false
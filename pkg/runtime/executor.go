@@ -25,7 +25,9 @@ import (
 	"github.com/wagoodman/bashful/pkg/config"
 	"github.com/wagoodman/bashful/pkg/log"
 	"github.com/wagoodman/bashful/utils"
-	"os"
+	"sort"
+	"sync"
+	"sync/atomic"
 	"time"
 )
 
@@ -33,17 +35,26 @@ func newExecutorStats() *TaskStatistics {
 	return &TaskStatistics{
 		Failed:    make([]*Task, 0),
 		Completed: make([]*Task, 0),
+		Skipped:   make([]*Task, 0),
+		UpToDate:  make([]*Task, 0),
+		Killed:    make([]*Task, 0),
 	}
 }
 
 func newExecutor(cfg *config.Config) *Executor {
 	executor := &Executor{
-		Environment:   make(map[string]string, 0),
-		eventHandlers: make([]EventHandler, 0),
-		config:        cfg,
-		Tasks:         make([]*Task, 0),
-		Statistics:    newExecutorStats(),
-		cmdEtaCache:   make(map[string]time.Duration, 0),
+		Environment:      make(map[string]string, 0),
+		eventHandlers:    make([]EventHandler, 0),
+		config:           cfg,
+		Tasks:            make([]*Task, 0),
+		Statistics:       newExecutorStats(),
+		cmdEtaCache:      make(map[string]time.Duration, 0),
+		fingerprintCache: make(map[string]string, 0),
+		events:           make(chan TaskEvent),
+		active:           make([]*Task, 0),
+		controls:         make(chan taskControl, 100),
+		conditions:       make(chan conditionResult),
+		poolUsage:        make(map[string]int, 0),
 	}
 
 	for _, taskConfig := range cfg.TaskConfigs {
@@ -51,49 +62,109 @@ func newExecutor(cfg *config.Config) *Executor {
 		task := NewTask(taskConfig, &cfg.Options)
 		executor.Tasks = append(executor.Tasks, task)
 	}
+	executor.resolveDependencies(executor.Tasks)
+
+	for _, taskConfig := range cfg.OnFailureTaskConfigs {
+		executor.onFailureTasks = append(executor.onFailureTasks, newHookTask(taskConfig, &cfg.Options, HookOnFailure))
+	}
+
+	for _, taskConfig := range cfg.FinallyTaskConfigs {
+		executor.finallyTasks = append(executor.finallyTasks, newHookTask(taskConfig, &cfg.Options, HookFinally))
+	}
+	executor.resolveDependencies(executor.finallyTasks)
 
 	return executor
 }
 
-// estimateRuntime fetches and reads a cache file from disk containing CmdString-to-ETASeconds. Note: this this must be done before fetching/parsing the run.yaml
-func (executor *Executor) readEtaCache() {
-	// create the cache dirs if they do not already exist
-	if _, err := os.Stat(executor.config.CachePath); os.IsNotExist(err) {
-		os.Mkdir(executor.config.CachePath, 0755)
+// allTasks returns all top-level Tasks that may be run, including the global 'on-failure' and 'finally' Tasks (the
+// per-task 'on-failure' commands are run with the same 'sudo' setting as the failed Task and never reference a url)
+func (executor *Executor) allTasks() []*Task {
+	return append(append(append([]*Task{}, executor.Tasks...), executor.onFailureTasks...), executor.finallyTasks...)
+}
+
+// newHookTask creates a Task (and all child Tasks) that is run within the given cleanup section
+func newHookTask(taskConfig config.TaskConfig, options *config.Options, hook string) *Task {
+	task := NewTask(taskConfig, options)
+	task.Hook = hook
+	for _, subTask := range task.Descendants() {
+		subTask.Hook = hook
+	}
+	return task
+}
+
+// resolveDependencies determines which of the given Tasks must finish before another may be started. Tasks are run
+// strictly in the order given unless any task declares 'needs', in which case only the declared dependencies are honored.
+func (executor *Executor) resolveDependencies(tasks []*Task) {
+	explicitDependencies := false
+	for _, task := range tasks {
+		if len(task.Config.Needs) > 0 {
+			explicitDependencies = true
+			break
+		}
 	}
 
-	// read the time cache
+	for idx, task := range tasks {
+		if !explicitDependencies {
+			if idx > 0 {
+				task.dependencies = []*Task{tasks[idx-1]}
+			}
+			continue
+		}
+
+		// note: there may be several tasks with the same id (for-each replicas), all of which must finish.
+		// Any needed task which is not found has been pruned (e.g. by tags) and is not waited on.
+		for _, need := range task.Config.Needs {
+			for _, candidate := range tasks {
+				if candidate.Config.Id == need {
+					task.dependencies = append(task.dependencies, candidate)
+				}
+			}
+		}
+	}
+}
+
+// readEtaCache reads a cache file from disk containing CmdString-to-ETASeconds (without creating any cache dirs, see Client.Run)
+func (executor *Executor) readEtaCache() {
 	executor.cmdEtaCache = make(map[string]time.Duration)
 	if utils.DoesFileExist(executor.config.EtaCachePath) {
 		err := utils.Load(executor.config.EtaCachePath, &executor.cmdEtaCache)
-		log.LogToMain(fmt.Sprintf("unable to load command eta cache: %v", err), log.StyleError)
+		if err != nil {
+			log.LogToMain(fmt.Sprintf("unable to load command eta cache: %v", err), log.StyleError)
+		}
 	}
-
 }
 
-// estimateRuntime accumulates the ETA for all planned tasks
+// estimateRuntime accumulates the ETA for all planned tasks (including 'finally' tasks, which are always run)
 func (executor *Executor) estimateRuntime() {
 	executor.readEtaCache()
 
 	for _, task := range executor.Tasks {
-		if task.Config.CmdString != "" || task.Config.URL != "" {
-			executor.Statistics.Total++
-			if eta, ok := executor.cmdEtaCache[task.Config.CmdString]; ok {
-				task.Command.addEstimatedRuntime(eta)
-			}
+		executor.planTask(task)
+	}
+	for _, task := range executor.finallyTasks {
+		executor.planTask(task)
+	}
+}
+
+// planTask includes the given Task (and all nested child Tasks) in the runtime statistics and overall ETA
+func (executor *Executor) planTask(task *Task) {
+	if task.Config.CmdString != "" || task.Config.URL != "" {
+		executor.Statistics.Total++
+		if eta, ok := executor.cmdEtaCache[task.Config.CmdString]; ok {
+			task.Command.addEstimatedRuntime(eta)
 		}
+	}
 
-		for _, subTask := range task.Children {
-			if subTask.Config.CmdString != "" || subTask.Config.URL != "" {
-				executor.Statistics.Total++
-				if eta, ok := executor.cmdEtaCache[subTask.Config.CmdString]; ok {
-					subTask.Command.addEstimatedRuntime(eta)
-				}
+	for _, subTask := range task.Descendants() {
+		if subTask.Config.CmdString != "" || subTask.Config.URL != "" {
+			executor.Statistics.Total++
+			if eta, ok := executor.cmdEtaCache[subTask.Config.CmdString]; ok {
+				subTask.Command.addEstimatedRuntime(eta)
 			}
 		}
-
-		executor.config.TotalEtaSeconds += task.estimateRuntime()
 	}
+
+	executor.config.TotalEtaSeconds += task.estimateRuntime()
 }
 
 func (executor *Executor) addEventHandler(handler EventHandler) {
@@ -101,90 +172,490 @@ func (executor *Executor) addEventHandler(handler EventHandler) {
 	executor.eventHandlers = append(executor.eventHandlers, handler)
 }
 
-// startNextSubTasks will kick start the maximum allowed number of commands (both primary and child task commands). Repeated invocation will iterate to new commands (and not repeat already markCompleted commands)
+// dependenciesMet indicates if the given Task can be scheduled. Explicitly needed Tasks must have finished (in which
+// case the first needed Task that did not succeed is returned), while Tasks that are simply ordered before the given
+// Task need only to have finished.
+func (executor *Executor) dependenciesMet(task *Task) (bool, *Task) {
+	var failedDependency *Task
+	for _, dependency := range task.dependencies {
+		if !dependency.finished {
+			return false, nil
+		}
+		if len(task.Config.Needs) > 0 && failedDependency == nil && (dependency.FailedChildren > 0 || dependency.blocked) {
+			failedDependency = dependency
+		}
+	}
+	return true, failedDependency
+}
+
+// startNextSubTasks will kick start the maximum allowed number of commands (both primary and nested child task commands). Repeated invocation will iterate to new commands (and not repeat already markCompleted commands)
 func (executor *Executor) startNextSubTasks(task *Task) {
-	// Note that the parent task result channel and waiter are used for all Tasks and child Tasks
-	if task.Config.CmdString != "" && !task.Started && executor.Statistics.Running < task.Options.MaxParallelCmds {
-		go task.Execute(task.events, &task.waiter, executor.Environment)
-		task.Started = true
-		executor.Statistics.Running++
-	}
-	for idx := 0; executor.Statistics.Running < task.Options.MaxParallelCmds && idx < len(task.Children); idx++ {
-		if task.Children[idx].Started {
+	// Note that the parent task waiter is used for all Tasks and child Tasks
+	if task.conditionPending {
+		return
+	}
+	if task.Config.CmdString != "" && !task.Started && executor.Statistics.Running < task.Options.MaxParallelCmds && executor.acquirePool(task) {
+		executor.startTask(task, &task.waiter, copyEnvironment(task.environment))
+	}
+	executor.startChildTasks(task, &task.waiter)
+}
+
+// startChildTasks starts the next commands of all (nested) child Tasks of the given Task. The child Tasks of a parallel
+// group are run concurrently, while the child Tasks of a sequential group (see 'tasks' and 'steps') are run one after
+// another (only once the group command has completed).
+func (executor *Executor) startChildTasks(task *Task, waiter *sync.WaitGroup) {
+	if task.sequential && task.Config.CmdString != "" && !task.Completed {
+		return
+	}
+
+	for idx, subTask := range task.Children {
+		if executor.Statistics.Running >= task.Options.MaxParallelCmds {
+			return
+		}
+		if task.sequential && idx > 0 && task.Children[idx-1].hasRemainingCommands() {
+			return
+		}
+
+		// the remaining steps of a task are not run once a step has failed
+		if len(task.Config.Steps) > 0 && task.FailedChildren > 0 && subTask.SkipReason == "" {
+			subTask.SkipReason = "a previous step did not succeed"
+		}
+
+		executor.enterTask(subTask)
+		if subTask.conditionPending {
+			// the child Task (and all of its own child Tasks) is started once the conditions have been evaluated
 			continue
 		}
-		subTask := task.Children[idx]
-		go subTask.Execute(task.events, &task.waiter, nil)
-		subTask.Started = true
-		executor.Statistics.Running++
+		if subTask.Config.CmdString != "" && !subTask.Started && executor.acquirePool(subTask) {
+			// each command is given a copy since all child Tasks may be running at the same time (the executor
+			// environment is updated with the env vars of all commands once the top-level Task has finished)
+			executor.startTask(subTask, waiter, copyEnvironment(subTask.environment))
+		}
+		executor.startChildTasks(subTask, waiter)
 	}
 }
 
-// Execute will run the current Tasks primary command and/or all child commands. When execution has markCompleted, the screen frame will advance.
-func (executor *Executor) execute(task *Task) error {
+// enterTask determines if the given child Task (and all of its own child Tasks) should be skipped once the Task is first reached
+func (executor *Executor) enterTask(task *Task) {
+	if task.entered {
+		return
+	}
+	task.entered = true
+	task.environment = executor.childEnvironment(task)
 
-	for _, handler := range executor.eventHandlers {
-		handler.Register(task)
+	// a skipped parent task skips all child tasks too
+	if !task.parent.resumed && task.parent.SkipReason != "" {
+		task.SkipReason = task.parent.SkipReason
+	}
+	if task.SkipReason == "" {
+		executor.checkConditions(task)
 	}
+	executor.renderEnteredTask(task)
+}
 
-	executor.startNextSubTasks(task)
+// startTask runs the given Task command in the background, unless the Task should not be run, in which case the Task is immediately completed as skipped
+func (executor *Executor) startTask(task *Task, waiter *sync.WaitGroup, environment map[string]string) {
+	task.Started = true
+	executor.Statistics.Running++
 
-	for executor.Statistics.Running > 0 {
-		event := <-task.events
+	if task.parent != nil {
+		executor.enterTask(task)
+	}
 
-		// manage completed tasks...
-		if event.Complete {
-			event.Task.Completed = true
+	if executor.interrupted && task.SkipReason == "" {
+		task.SkipReason = "interrupted"
+	}
 
-			executor.Statistics.Completed = append(executor.Statistics.Completed, event.Task)
-			executor.cmdEtaCache[task.Config.CmdString] = event.Task.Command.StopTime.Sub(event.Task.Command.StartTime)
-			executor.Statistics.Running--
+	if task.resumed && task.SkipReason == "" {
+		task.SkipReason = resumedReason
+	}
 
-			executor.startNextSubTasks(task)
+	if task.SkipReason != "" {
+		executor.onEvent(TaskEvent{Task: task, Status: StatusSkipped, Complete: true, ReturnCode: -1})
+		return
+	}
 
-			task.Status = event.Status
+	// a Task command that could not be rendered when entered fails here
+	err := executor.renderTemplates(task)
+	if err != nil {
+		task.Command.FailureReason = err.Error()
+		task.Command.errorBuffer.WriteString(err.Error() + "\n")
+		executor.onEvent(TaskEvent{Task: task, Status: StatusError, Stderr: utils.Red(err.Error()), Complete: true, ReturnCode: -1})
+		return
+	}
+
+	if !task.tracksInputs() {
+		go task.Execute(executor.events, waiter, environment)
+		return
+	}
+
+	// hashing all inputs may take a while, so this is done in the background along with running the command
+	task.fingerprintKey = task.fingerprintCacheKey(environment)
+	previous := executor.fingerprintCache[task.fingerprintKey]
+	go func() {
+		if task.upToDate(previous) {
+			executor.events <- TaskEvent{Task: task, Status: StatusUpToDate, Complete: true, ReturnCode: -1}
+			return
+		}
+		task.Execute(executor.events, waiter, environment)
+	}()
+}
 
-			if event.Status == StatusError {
-				// keep note of the failed task for an after task report
-				task.FailedChildren++
-				executor.Statistics.Failed = append(executor.Statistics.Failed, event.Task)
+// renderTemplates renders any task fields that reference registered vars (rebuilding the command if anything has changed)
+func (executor *Executor) renderTemplates(task *Task) error {
+	rendered, err := config.RenderTaskTemplates(&task.Config, executor.templateVars())
+	if err != nil || !rendered {
+		return err
+	}
+
+	estimatedRuntime := task.Command.EstimatedRuntime
+	task.Command = newCommand(task.Config)
+	task.Command.addEstimatedRuntime(estimatedRuntime)
+	return nil
+}
+
+// renderEnteredTask renders the task fields that reference registered vars as soon as the Task is entered (so the
+// rendered name is shown). A Task command that cannot be rendered fails once started, while a group of Tasks that cannot
+// be rendered is skipped (along with all child Tasks).
+func (executor *Executor) renderEnteredTask(task *Task) {
+	err := executor.renderTemplates(task)
+	if err != nil && task.Config.CmdString == "" && task.SkipReason == "" {
+		task.SkipReason = err.Error()
+	}
+}
+
+// templateVars is every 'vars' value along with the values of all vars registered by Tasks so far
+func (executor *Executor) templateVars() map[string]interface{} {
+	vars := make(map[string]interface{}, len(executor.config.Vars)+len(executor.config.RegisteredVars))
+	for key, value := range executor.config.Vars {
+		vars[key] = value
+	}
+	for name := range executor.config.RegisteredVars {
+		if value, ok := executor.Environment[name]; ok {
+			vars[name] = value
+		}
+	}
+	return vars
+}
+
+// scheduleReadyTasks registers all of the given top-level Tasks whose dependencies have been met and starts as many commands as allowed across all active Tasks
+func (executor *Executor) scheduleReadyTasks(tasks []*Task) {
+	if !isExitSignaled() {
+		for _, task := range tasks {
+			if task.scheduled {
+				continue
+			}
+			met, failedDependency := executor.dependenciesMet(task)
+			if !met {
+				continue
+			}
+			task.scheduled = true
+			task.environment = copyEnvironment(executor.Environment)
+			executor.active = append(executor.active, task)
+
+			if failedDependency != nil {
+				task.blocked = true
+				task.SkipReason = fmt.Sprintf("needed task '%s' did not succeed", failedDependency.Config.Name)
+			} else if task.SkipReason != userSkipReason {
+				task.SkipReason = ""
+				executor.checkConditions(task)
+			}
+
+			executor.renderEnteredTask(task)
+
+			for _, handler := range executor.eventHandlers {
+				handler.Register(task)
 			}
 		}
+	}
+
+	for _, task := range executor.active {
+		executor.startNextSubTasks(task)
+	}
+}
 
-		// notify all handlers...
+// onEvent records the outcome of any completed command and notifies all handlers of the given event
+func (executor *Executor) onEvent(event TaskEvent) {
+	task := event.Task.root()
+
+	// manage completed tasks...
+	if event.Complete {
+		event.Task.Completed = true
+		executor.releasePool(event.Task)
+
+		executor.Statistics.Completed = append(executor.Statistics.Completed, event.Task)
+		executor.Statistics.Running--
+
+		// a skipped child task should not mask the status of the tasks that have been run
+		for parent := event.Task.parent; parent != nil; parent = parent.parent {
+			if event.Status != StatusSkipped || parent.Status == StatusPending {
+				parent.Status = event.Status
+			}
+		}
+		event.Task.Status = event.Status
+
+		switch event.Status {
+		case StatusSkipped:
+			executor.Statistics.Skipped = append(executor.Statistics.Skipped, event.Task)
+		case StatusUpToDate:
+			executor.Statistics.UpToDate = append(executor.Statistics.UpToDate, event.Task)
+		default:
+			executor.cmdEtaCache[event.Task.Config.CmdString] = event.Task.Command.StopTime.Sub(event.Task.Command.StartTime)
+
+			if event.Task.fingerprint != "" && event.Task.isSuccessCode(event.ReturnCode) && event.Task.Command.FailureReason == "" {
+				executor.fingerprintCache[event.Task.fingerprintKey] = event.Task.fingerprint
+			}
+
+			// any Task command may register its output for future Tasks (available as a template var right away, and passed
+			// on as an env var like any exported env var, see 'childEnvironment' and 'mergeEnvironment')
+			for key, value := range event.Task.registeredEnvironment() {
+				executor.Environment[key] = value
+			}
+		}
+
+		if event.Status == StatusError || event.Status == StatusTimedOut {
+			// keep note of the failed task for an after task report
+			for failedTask := event.Task; failedTask != nil; failedTask = failedTask.parent {
+				failedTask.FailedChildren++
+			}
+			executor.Statistics.Failed = append(executor.Statistics.Failed, event.Task)
+			if event.Task.killed {
+				executor.Statistics.Killed = append(executor.Statistics.Killed, event.Task)
+			}
+		}
+
+		executor.recordRunState(event.Task, event.Status)
+	}
+
+	// notify all handlers...
+	for _, handler := range executor.eventHandlers {
+		handler.OnEvent(task, event)
+	}
+}
+
+// finishCompletedTasks unregisters all active Tasks which have no remaining commands to run, returning the number of Tasks finished
+func (executor *Executor) finishCompletedTasks() int {
+	var finished int
+	for idx := 0; idx < len(executor.active); idx++ {
+		task := executor.active[idx]
+		if task.hasRemainingCommands() {
+			continue
+		}
+
+		if !isExitSignaled() {
+			task.waiter.Wait()
+		}
+
+		// any warnings are shown before the tasks are unregistered
+		executor.mergeEnvironment(task)
+
+		// we should be done with all tasks/subtasks at this point, unregister everything
+		for _, subTask := range task.Descendants() {
+			for _, handler := range executor.eventHandlers {
+				handler.Unregister(subTask)
+			}
+		}
 		for _, handler := range executor.eventHandlers {
-			handler.OnEvent(task, event)
+			handler.Unregister(task)
 		}
+
+		task.finished = true
+		executor.active = append(executor.active[:idx], executor.active[idx+1:]...)
+		idx--
+		finished++
 	}
+	return finished
+}
 
-	close(task.events)
+// mergeEnvironment passes the env vars exported by all commands of the given finished top-level Task on to future Tasks
+// (see 'collectExports'). Any conflicting values exported by concurrently run commands are noted as warnings (shown as
+// stderr of the overriding Task and in the report after all Tasks have run).
+func (executor *Executor) mergeEnvironment(task *Task) {
+	exported, _, conflicts := collectExports(task)
+	for _, conflict := range conflicts {
+		log.LogToMain(conflict.message, log.StyleError)
+		executor.Statistics.Warnings = append(executor.Statistics.Warnings, conflict.message)
+		executor.onEvent(TaskEvent{Task: conflict.task, Status: conflict.task.Status, Stderr: utils.Red("warning: " + conflict.message), ReturnCode: -1})
+	}
 
-	if !exitSignaled {
-		task.waiter.Wait()
+	for key, value := range exported {
+		executor.Environment[key] = value
 	}
+}
 
-	// we should be done with all tasks/subtasks at this point, unregister everything
-	for _, subTask := range task.Children {
-		for _, handler := range executor.eventHandlers {
-			handler.Unregister(subTask)
+// childEnvironment returns the env vars given to the given child Task once entered: the env vars of the parent Task,
+// along with (for a sequential group) the env vars exported by the group command and by all preceding child Tasks
+func (executor *Executor) childEnvironment(task *Task) map[string]string {
+	parent := task.parent
+	environment := copyEnvironment(parent.environment)
+	if !parent.sequential {
+		return environment
+	}
+
+	if parent.Config.CmdString != "" && parent.Completed {
+		for key, value := range parent.exportedEnvironment() {
+			environment[key] = value
 		}
 	}
-	for _, handler := range executor.eventHandlers {
-		handler.Unregister(task)
+	for _, sibling := range parent.Children {
+		if sibling == task {
+			break
+		}
+		exported, _, _ := collectExports(sibling)
+		for key, value := range exported {
+			environment[key] = value
+		}
 	}
-	return nil
+	return environment
 }
 
-func (executor *Executor) run() error {
-	for _, task := range executor.Tasks {
-		// todo: execute should return error and be checked here
-		executor.execute(task)
+// exportConflict describes an env var exported by the given Task which overrides a different value exported by a concurrently run Task
+type exportConflict struct {
+	task    *Task
+	message string
+}
 
-		if exitSignaled {
-			log.LogToMain("signaled to exit", log.StyleMajor)
+// collectExports returns the env vars exported by the given Task command and all (nested) child Task commands, along
+// with the Task that exported each env var. The commands are merged in declaration order (the Task command first, then
+// all child Task commands depth first), so the last declared command wins. Commands that are run concurrently (the
+// child Tasks of a parallel group) may export different values for the same env var, each of which is described as a conflict.
+func collectExports(task *Task) (map[string]string, map[string]*Task, []exportConflict) {
+	exported := make(map[string]string)
+	exportedBy := make(map[string]*Task)
+	var conflicts []exportConflict
+
+	merge := func(values map[string]string, origins map[string]*Task, concurrent bool) {
+		keys := make([]string, 0, len(values))
+		for key := range values {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+
+		for _, key := range keys {
+			if previous, ok := exportedBy[key]; ok && concurrent && exported[key] != values[key] {
+				message := fmt.Sprintf("env var '%s' exported by task '%s' overrides the value exported by task '%s'", key, origins[key].Config.Name, previous.Config.Name)
+				conflicts = append(conflicts, exportConflict{task: origins[key], message: message})
+			}
+			exported[key] = values[key]
+			exportedBy[key] = origins[key]
+		}
+	}
+
+	if task.Config.CmdString != "" && task.Completed {
+		values := task.exportedEnvironment()
+		origins := make(map[string]*Task, len(values))
+		for key := range values {
+			origins[key] = task
+		}
+		merge(values, origins, false)
+	}
+	for _, subTask := range task.Children {
+		values, origins, subConflicts := collectExports(subTask)
+		conflicts = append(conflicts, subConflicts...)
+		merge(values, origins, !task.sequential)
+	}
+	return exported, exportedBy, conflicts
+}
+
+// copyEnvironment returns a copy of the given env vars
+func copyEnvironment(environment map[string]string) map[string]string {
+	copied := make(map[string]string, len(environment))
+	for key, value := range environment {
+		copied[key] = value
+	}
+	return copied
+}
+
+// interrupt stops all running commands and prevents any further commands from being started
+func (executor *Executor) interrupt() {
+	log.LogToMain("keyboard interrupt, stopping all running tasks", log.StyleMajor)
+	signalExit(true)
+	executor.interrupted = true
+	for _, task := range executor.active {
+		task.Kill()
+	}
+}
+
+// runTasks runs the given top-level Tasks until all have finished (or the run has been halted). Any value received
+// from the given interrupts channel stops all running Tasks.
+func (executor *Executor) runTasks(tasks []*Task, interrupts <-chan struct{}) {
+	for {
+		executor.scheduleReadyTasks(tasks)
+
+		// finishing tasks may allow dependent tasks to be scheduled
+		if executor.finishCompletedTasks() > 0 {
+			continue
+		}
+
+		if len(executor.active) == 0 {
+			// any pending user requests (e.g. retrying a failed task) may schedule tasks again
+			select {
+			case control := <-executor.controls:
+				executor.onControl(control)
+				continue
+			default:
+			}
 			break
 		}
+
+		select {
+		case event := <-executor.events:
+			executor.onEvent(event)
+		case control := <-executor.controls:
+			executor.onControl(control)
+		case result := <-executor.conditions:
+			executor.onConditionResult(result)
+		case <-interrupts:
+			interrupts = nil
+			executor.interrupt()
+		}
+	}
+}
+
+// runHooks runs the per-task and global 'on-failure' Tasks (only if any Task has failed or the run was halted) followed
+// by all 'finally' Tasks. Hooks are always run to completion regardless of any earlier failure or keyboard interrupt.
+func (executor *Executor) runHooks() {
+	halted := isExitSignaled()
+	signalExit(false)
+	executor.interrupted = false
+
+	if len(executor.Statistics.Failed) > 0 || halted {
+		var onFailureTasks []*Task
+		for _, failedTask := range executor.Statistics.Failed {
+			for _, taskConfig := range failedTask.Config.OnFailureTaskConfigs {
+				onFailureTasks = append(onFailureTasks, newHookTask(taskConfig, failedTask.Options, HookOnFailure))
+			}
+		}
+		onFailureTasks = append(onFailureTasks, executor.onFailureTasks...)
+		executor.resolveDependencies(onFailureTasks)
+
+		for _, task := range onFailureTasks {
+			executor.planTask(task)
+		}
+		executor.runTasks(onFailureTasks, nil)
+	}
+
+	executor.runTasks(executor.finallyTasks, nil)
+
+	signalExit(isExitSignaled() || halted)
+}
+
+func (executor *Executor) run() error {
+	atomic.StoreInt32(&executing, 1)
+	defer atomic.StoreInt32(&executing, 0)
+
+	executor.loadRunState()
+	executor.readFingerprintCache()
+	executor.runTasks(executor.Tasks, interrupted)
+
+	if isExitSignaled() {
+		log.LogToMain("signaled to exit", log.StyleMajor)
+	} else if len(executor.Statistics.Failed) == 0 {
+		executor.clearRunState()
 	}
+
+	executor.runHooks()
+
 	for _, handler := range executor.eventHandlers {
 		handler.Close()
 	}
@@ -194,5 +665,10 @@ func (executor *Executor) run() error {
 		log.LogToMain(fmt.Sprintf("unable to save command eta cache: %v", err), log.StyleError)
 	}
 
+	err = utils.Save(executor.config.FingerprintCachePath, &executor.fingerprintCache)
+	if err != nil {
+		log.LogToMain(fmt.Sprintf("unable to save fingerprint cache: %v", err), log.StyleError)
+	}
+
 	return nil
 }
@@ -4,7 +4,14 @@ import (
 	"fmt"
 	"github.com/lunixbochs/vtclean"
 	"github.com/wagoodman/bashful/pkg/config"
+	"github.com/wagoodman/bashful/utils"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
 	"testing"
+	"time"
 )
 
 // Test harness...
@@ -87,7 +94,7 @@ type executorTestCase struct {
 }
 
 func runExecutorCase(t *testing.T, testCase *executorTestCase) {
-	exitSignaled = false
+	signalExit(false)
 	handler := newTestHander(t)
 	cfg, err := config.NewConfig(testCase.runYaml, nil)
 	if err != nil {
@@ -199,9 +206,9 @@ tasks:
 		runYaml: runYaml,
 		expectedEvents: []expectedActionEvent{
 			{action: actionRegister, taskName: "easy task 1", eventTaskName: "", event: nil},
-			{action: actionOnEvent, taskName: "easy task 1", eventTaskName: "", event: &TaskEvent{nil, StatusRunning, "", "", false, -1}},
-			{action: actionOnEvent, taskName: "easy task 1", eventTaskName: "", event: &TaskEvent{nil, StatusRunning, "task1", "", false, -1}},
-			{action: actionOnEvent, taskName: "easy task 1", eventTaskName: "", event: &TaskEvent{nil, StatusSuccess, "", "", true, 0}},
+			{action: actionOnEvent, taskName: "easy task 1", eventTaskName: "", event: &TaskEvent{Status: StatusRunning, ReturnCode: -1}},
+			{action: actionOnEvent, taskName: "easy task 1", eventTaskName: "", event: &TaskEvent{Status: StatusRunning, Stdout: "task1", ReturnCode: -1}},
+			{action: actionOnEvent, taskName: "easy task 1", eventTaskName: "", event: &TaskEvent{Status: StatusSuccess, Complete: true, ReturnCode: 0}},
 			{action: actionUnregister, taskName: "easy task 1", eventTaskName: "", event: nil},
 			{action: actionClose, taskName: "", eventTaskName: "", event: nil},
 		},
@@ -223,8 +230,8 @@ tasks:
 		runYaml: runYaml,
 		expectedEvents: []expectedActionEvent{
 			{action: actionRegister, taskName: "easy task 1", eventTaskName: "", event: nil},
-			{action: actionOnEvent, taskName: "easy task 1", eventTaskName: "", event: &TaskEvent{nil, StatusRunning, "", "", false, -1}},
-			{action: actionOnEvent, taskName: "easy task 1", eventTaskName: "", event: &TaskEvent{nil, StatusError, "", "", true, 1}},
+			{action: actionOnEvent, taskName: "easy task 1", eventTaskName: "", event: &TaskEvent{Status: StatusRunning, ReturnCode: -1}},
+			{action: actionOnEvent, taskName: "easy task 1", eventTaskName: "", event: &TaskEvent{Status: StatusError, Complete: true, ReturnCode: 1}},
 			{action: actionUnregister, taskName: "easy task 1", eventTaskName: "", event: nil},
 			{action: actionClose, taskName: "", eventTaskName: "", event: nil},
 		},
@@ -234,6 +241,46 @@ tasks:
 	runExecutorCase(t, &testCase)
 }
 
+func Test_Executor_run_assertions(t *testing.T) {
+	var runYaml = []byte(`
+config:
+  stop-on-failure: false
+tasks:
+  - name: grep task
+    cmd: exit 1
+    success-codes: [0, 1]
+  - name: expect task
+    cmd: echo skipped
+    expect-stdout: ^passed$
+  - name: fail output task
+    cmd: 'echo "warning: deprecated" >&2'
+    fail-on-output: deprecated
+`)
+	var testCase = executorTestCase{
+		runYaml: runYaml,
+		expectedEvents: []expectedActionEvent{
+			{action: actionRegister, taskName: "grep task", eventTaskName: "", event: nil},
+			{action: actionOnEvent, taskName: "grep task", eventTaskName: "", event: &TaskEvent{Status: StatusRunning, ReturnCode: -1}},
+			{action: actionOnEvent, taskName: "grep task", eventTaskName: "", event: &TaskEvent{Status: StatusSuccess, Complete: true, ReturnCode: 1}},
+			{action: actionUnregister, taskName: "grep task", eventTaskName: "", event: nil},
+			{action: actionRegister, taskName: "expect task", eventTaskName: "", event: nil},
+			{action: actionOnEvent, taskName: "expect task", eventTaskName: "", event: &TaskEvent{Status: StatusRunning, ReturnCode: -1}},
+			{action: actionOnEvent, taskName: "expect task", eventTaskName: "", event: &TaskEvent{Status: StatusRunning, Stdout: "skipped", ReturnCode: -1}},
+			{action: actionOnEvent, taskName: "expect task", eventTaskName: "", event: &TaskEvent{Status: StatusError, Complete: true, ReturnCode: 0}},
+			{action: actionUnregister, taskName: "expect task", eventTaskName: "", event: nil},
+			{action: actionRegister, taskName: "fail output task", eventTaskName: "", event: nil},
+			{action: actionOnEvent, taskName: "fail output task", eventTaskName: "", event: &TaskEvent{Status: StatusRunning, ReturnCode: -1}},
+			{action: actionOnEvent, taskName: "fail output task", eventTaskName: "", event: &TaskEvent{Status: StatusRunning, Stderr: "warning: deprecated", ReturnCode: -1}},
+			{action: actionOnEvent, taskName: "fail output task", eventTaskName: "", event: &TaskEvent{Status: StatusError, Complete: true, ReturnCode: 0}},
+			{action: actionUnregister, taskName: "fail output task", eventTaskName: "", event: nil},
+			{action: actionClose, taskName: "", eventTaskName: "", event: nil},
+		},
+		expectedEnv: map[string]string{},
+	}
+
+	runExecutorCase(t, &testCase)
+}
+
 func Test_Executor_run_serialTasks_success(t *testing.T) {
 	var runYaml = []byte(`
 config:
@@ -248,12 +295,12 @@ tasks:
 		runYaml: runYaml,
 		expectedEvents: []expectedActionEvent{
 			{action: actionRegister, taskName: "easy task 1", eventTaskName: "", event: nil},
-			{action: actionOnEvent, taskName: "easy task 1", eventTaskName: "", event: &TaskEvent{nil, StatusRunning, "", "", false, -1}},
-			{action: actionOnEvent, taskName: "easy task 1", eventTaskName: "", event: &TaskEvent{nil, StatusSuccess, "", "", true, 0}},
+			{action: actionOnEvent, taskName: "easy task 1", eventTaskName: "", event: &TaskEvent{Status: StatusRunning, ReturnCode: -1}},
+			{action: actionOnEvent, taskName: "easy task 1", eventTaskName: "", event: &TaskEvent{Status: StatusSuccess, Complete: true, ReturnCode: 0}},
 			{action: actionUnregister, taskName: "easy task 1", eventTaskName: "", event: nil},
 			{action: actionRegister, taskName: "easy task 2", eventTaskName: "", event: nil},
-			{action: actionOnEvent, taskName: "easy task 2", eventTaskName: "", event: &TaskEvent{nil, StatusRunning, "", "", false, -1}},
-			{action: actionOnEvent, taskName: "easy task 2", eventTaskName: "", event: &TaskEvent{nil, StatusSuccess, "", "", true, 0}},
+			{action: actionOnEvent, taskName: "easy task 2", eventTaskName: "", event: &TaskEvent{Status: StatusRunning, ReturnCode: -1}},
+			{action: actionOnEvent, taskName: "easy task 2", eventTaskName: "", event: &TaskEvent{Status: StatusSuccess, Complete: true, ReturnCode: 0}},
 			{action: actionUnregister, taskName: "easy task 2", eventTaskName: "", event: nil},
 			{action: actionClose, taskName: "", eventTaskName: "", event: nil},
 		},
@@ -276,12 +323,12 @@ tasks:
 		runYaml: runYaml,
 		expectedEvents: []expectedActionEvent{
 			{action: actionRegister, taskName: "easy task 1", eventTaskName: "", event: nil},
-			{action: actionOnEvent, taskName: "easy task 1", eventTaskName: "", event: &TaskEvent{nil, StatusRunning, "", "", false, -1}},
-			{action: actionOnEvent, taskName: "easy task 1", eventTaskName: "", event: &TaskEvent{nil, StatusError, "", "", true, 1}},
+			{action: actionOnEvent, taskName: "easy task 1", eventTaskName: "", event: &TaskEvent{Status: StatusRunning, ReturnCode: -1}},
+			{action: actionOnEvent, taskName: "easy task 1", eventTaskName: "", event: &TaskEvent{Status: StatusError, Complete: true, ReturnCode: 1}},
 			{action: actionUnregister, taskName: "easy task 1", eventTaskName: "", event: nil},
 			{action: actionRegister, taskName: "easy task 2", eventTaskName: "", event: nil},
-			{action: actionOnEvent, taskName: "easy task 2", eventTaskName: "", event: &TaskEvent{nil, StatusRunning, "", "", false, -1}},
-			{action: actionOnEvent, taskName: "easy task 2", eventTaskName: "", event: &TaskEvent{nil, StatusSuccess, "", "", true, 0}},
+			{action: actionOnEvent, taskName: "easy task 2", eventTaskName: "", event: &TaskEvent{Status: StatusRunning, ReturnCode: -1}},
+			{action: actionOnEvent, taskName: "easy task 2", eventTaskName: "", event: &TaskEvent{Status: StatusSuccess, Complete: true, ReturnCode: 0}},
 			{action: actionUnregister, taskName: "easy task 2", eventTaskName: "", event: nil},
 			{action: actionClose, taskName: "", eventTaskName: "", event: nil},
 		},
@@ -305,8 +352,8 @@ tasks:
 		runYaml: runYaml,
 		expectedEvents: []expectedActionEvent{
 			{action: actionRegister, taskName: "easy task 1", eventTaskName: "", event: nil},
-			{action: actionOnEvent, taskName: "easy task 1", eventTaskName: "", event: &TaskEvent{nil, StatusRunning, "", "", false, -1}},
-			{action: actionOnEvent, taskName: "easy task 1", eventTaskName: "", event: &TaskEvent{nil, StatusError, "", "", true, 1}},
+			{action: actionOnEvent, taskName: "easy task 1", eventTaskName: "", event: &TaskEvent{Status: StatusRunning, ReturnCode: -1}},
+			{action: actionOnEvent, taskName: "easy task 1", eventTaskName: "", event: &TaskEvent{Status: StatusError, Complete: true, ReturnCode: 1}},
 			{action: actionUnregister, taskName: "easy task 1", eventTaskName: "", event: nil},
 			{action: actionClose, taskName: "", eventTaskName: "", event: nil},
 		},
@@ -330,13 +377,13 @@ tasks:
 		runYaml: runYaml,
 		expectedEvents: []expectedActionEvent{
 			{action: actionRegister, taskName: "easy task 1", eventTaskName: "", event: nil},
-			{action: actionOnEvent, taskName: "easy task 1", eventTaskName: "", event: &TaskEvent{nil, StatusRunning, "", "", false, -1}},
-			{action: actionOnEvent, taskName: "easy task 1", eventTaskName: "", event: &TaskEvent{nil, StatusSuccess, "", "", true, 0}},
+			{action: actionOnEvent, taskName: "easy task 1", eventTaskName: "", event: &TaskEvent{Status: StatusRunning, ReturnCode: -1}},
+			{action: actionOnEvent, taskName: "easy task 1", eventTaskName: "", event: &TaskEvent{Status: StatusSuccess, Complete: true, ReturnCode: 0}},
 			{action: actionUnregister, taskName: "easy task 1", eventTaskName: "", event: nil},
 			{action: actionRegister, taskName: "easy task 2", eventTaskName: "", event: nil},
-			{action: actionOnEvent, taskName: "easy task 2", eventTaskName: "", event: &TaskEvent{nil, StatusRunning, "", "", false, -1}},
-			{action: actionOnEvent, taskName: "easy task 2", eventTaskName: "", event: &TaskEvent{nil, StatusRunning, "42", "", false, -1}},
-			{action: actionOnEvent, taskName: "easy task 2", eventTaskName: "", event: &TaskEvent{nil, StatusSuccess, "", "", true, 0}},
+			{action: actionOnEvent, taskName: "easy task 2", eventTaskName: "", event: &TaskEvent{Status: StatusRunning, ReturnCode: -1}},
+			{action: actionOnEvent, taskName: "easy task 2", eventTaskName: "", event: &TaskEvent{Status: StatusRunning, Stdout: "42", ReturnCode: -1}},
+			{action: actionOnEvent, taskName: "easy task 2", eventTaskName: "", event: &TaskEvent{Status: StatusSuccess, Complete: true, ReturnCode: 0}},
 			{action: actionUnregister, taskName: "easy task 2", eventTaskName: "", event: nil},
 			{action: actionClose, taskName: "", eventTaskName: "", event: nil},
 		},
@@ -347,3 +394,837 @@ tasks:
 }
 
 // todo: missing parallel test cases
+
+func Test_Executor_run_env(t *testing.T) {
+	var runYaml = []byte(`
+config:
+  env:
+    GREETING: hello
+tasks:
+  - name: configured task
+    env:
+      NAME: world
+    cmd: echo "$GREETING $NAME"
+  - name: group
+    env:
+      GREETING: hi
+    parallel-tasks:
+      - name: child task
+        env:
+          NAME: child
+        cmd: echo "$GREETING $NAME"
+  - name: unconfigured task
+    cmd: echo "$GREETING ${NAME:-nobody}"
+`)
+	var testCase = executorTestCase{
+		runYaml: runYaml,
+		expectedEvents: []expectedActionEvent{
+			{action: actionRegister, taskName: "configured task"},
+			{action: actionOnEvent, taskName: "configured task", event: &TaskEvent{Status: StatusRunning, ReturnCode: -1}},
+			{action: actionOnEvent, taskName: "configured task", event: &TaskEvent{Status: StatusRunning, Stdout: "hello world", ReturnCode: -1}},
+			{action: actionOnEvent, taskName: "configured task", event: &TaskEvent{Status: StatusSuccess, Complete: true, ReturnCode: 0}},
+			{action: actionUnregister, taskName: "configured task"},
+			{action: actionRegister, taskName: "group"},
+			{action: actionOnEvent, taskName: "group", eventTaskName: "child task", event: &TaskEvent{Status: StatusRunning, ReturnCode: -1}},
+			{action: actionOnEvent, taskName: "group", eventTaskName: "child task", event: &TaskEvent{Status: StatusRunning, Stdout: "hi child", ReturnCode: -1}},
+			{action: actionOnEvent, taskName: "group", eventTaskName: "child task", event: &TaskEvent{Status: StatusSuccess, Complete: true, ReturnCode: 0}},
+			{action: actionUnregister, taskName: "child task"},
+			{action: actionUnregister, taskName: "group"},
+			{action: actionRegister, taskName: "unconfigured task"},
+			{action: actionOnEvent, taskName: "unconfigured task", event: &TaskEvent{Status: StatusRunning, ReturnCode: -1}},
+			{action: actionOnEvent, taskName: "unconfigured task", event: &TaskEvent{Status: StatusRunning, Stdout: "hello nobody", ReturnCode: -1}},
+			{action: actionOnEvent, taskName: "unconfigured task", event: &TaskEvent{Status: StatusSuccess, Complete: true, ReturnCode: 0}},
+			{action: actionUnregister, taskName: "unconfigured task"},
+			{action: actionClose},
+		},
+	}
+
+	runExecutorCase(t, &testCase)
+}
+
+func Test_Executor_run_register(t *testing.T) {
+	var runYaml = []byte(`
+config:
+  stop-on-failure: false
+tasks:
+  - name: version task
+    cmd: printf '  1.2.0\n\n'
+    register: VERSION
+    show-output: false
+  - name: check task
+    cmd: echo failed; exit 3
+    register: CHECK
+    register-return-code: true
+  - name: deploy {{ .Vars.VERSION }}
+    env:
+      RESULT: "{{ .Vars.CHECK }} ({{ .Vars.CHECK_RC }})"
+    cmd: echo "$VERSION {{ .Vars.VERSION }} $RESULT"
+`)
+	var testCase = executorTestCase{
+		runYaml: runYaml,
+		expectedEvents: []expectedActionEvent{
+			{action: actionRegister, taskName: "version task"},
+			{action: actionOnEvent, taskName: "version task", event: &TaskEvent{Status: StatusRunning, ReturnCode: -1}},
+			{action: actionOnEvent, taskName: "version task", event: &TaskEvent{Status: StatusRunning, Stdout: "  1.2.0", ReturnCode: -1}},
+			{action: actionOnEvent, taskName: "version task", event: &TaskEvent{Status: StatusRunning, Stdout: "", ReturnCode: -1}},
+			{action: actionOnEvent, taskName: "version task", event: &TaskEvent{Status: StatusSuccess, Complete: true, ReturnCode: 0}},
+			{action: actionUnregister, taskName: "version task"},
+			{action: actionRegister, taskName: "check task"},
+			{action: actionOnEvent, taskName: "check task", event: &TaskEvent{Status: StatusRunning, ReturnCode: -1}},
+			{action: actionOnEvent, taskName: "check task", event: &TaskEvent{Status: StatusRunning, Stdout: "failed", ReturnCode: -1}},
+			{action: actionOnEvent, taskName: "check task", event: &TaskEvent{Status: StatusError, Complete: true, ReturnCode: 3}},
+			{action: actionUnregister, taskName: "check task"},
+			{action: actionRegister, taskName: "deploy 1.2.0"},
+			{action: actionOnEvent, taskName: "deploy 1.2.0", event: &TaskEvent{Status: StatusRunning, ReturnCode: -1}},
+			{action: actionOnEvent, taskName: "deploy 1.2.0", event: &TaskEvent{Status: StatusRunning, Stdout: "1.2.0 1.2.0 failed (3)", ReturnCode: -1}},
+			{action: actionOnEvent, taskName: "deploy 1.2.0", event: &TaskEvent{Status: StatusSuccess, Complete: true, ReturnCode: 0}},
+			{action: actionUnregister, taskName: "deploy 1.2.0"},
+			{action: actionClose},
+		},
+		expectedEnv: map[string]string{
+			"VERSION":  "1.2.0",
+			"CHECK":    "failed",
+			"CHECK_RC": "3",
+		},
+	}
+
+	runExecutorCase(t, &testCase)
+}
+
+func Test_Executor_run_exportEnv(t *testing.T) {
+	var runYaml = []byte(`
+config:
+  export-env: ["APP_*"]
+tasks:
+  - name: export task
+    cwd: /
+    cmd: export APP_NOTES="$(printf 'first line\nsecond line')"; export OTHER=ignored
+  - name: private task
+    cmd: export APP_SECRET=hidden
+    export-env: []
+  - name: print task
+    cmd: echo "$APP_NOTES|${OTHER:-unset}|${APP_SECRET:-unset}"; test "$PWD" != /
+`)
+	var testCase = executorTestCase{
+		runYaml: runYaml,
+		expectedEvents: []expectedActionEvent{
+			{action: actionRegister, taskName: "export task"},
+			{action: actionOnEvent, taskName: "export task", event: &TaskEvent{Status: StatusRunning, ReturnCode: -1}},
+			{action: actionOnEvent, taskName: "export task", event: &TaskEvent{Status: StatusSuccess, Complete: true, ReturnCode: 0}},
+			{action: actionUnregister, taskName: "export task"},
+			{action: actionRegister, taskName: "private task"},
+			{action: actionOnEvent, taskName: "private task", event: &TaskEvent{Status: StatusRunning, ReturnCode: -1}},
+			{action: actionOnEvent, taskName: "private task", event: &TaskEvent{Status: StatusSuccess, Complete: true, ReturnCode: 0}},
+			{action: actionUnregister, taskName: "private task"},
+			{action: actionRegister, taskName: "print task"},
+			{action: actionOnEvent, taskName: "print task", event: &TaskEvent{Status: StatusRunning, ReturnCode: -1}},
+			{action: actionOnEvent, taskName: "print task", event: &TaskEvent{Status: StatusRunning, Stdout: "first line", ReturnCode: -1}},
+			{action: actionOnEvent, taskName: "print task", event: &TaskEvent{Status: StatusRunning, Stdout: "second line|unset|unset", ReturnCode: -1}},
+			{action: actionOnEvent, taskName: "print task", event: &TaskEvent{Status: StatusSuccess, Complete: true, ReturnCode: 0}},
+			{action: actionUnregister, taskName: "print task"},
+			{action: actionClose},
+		},
+		expectedEnv: map[string]string{
+			"APP_NOTES": "first line\nsecond line",
+		},
+	}
+
+	runExecutorCase(t, &testCase)
+}
+
+func Test_Executor_run_nestedGroups(t *testing.T) {
+	var runYaml = []byte(`
+config:
+  stop-on-failure: false
+tasks:
+  - name: build
+    tasks:
+      - name: compile
+        cmd: true
+      - name: checks
+        parallel-tasks:
+          - name: lint
+            cmd: false
+      - name: package
+        cmd: true
+`)
+	var testCase = executorTestCase{
+		runYaml: runYaml,
+		expectedEvents: []expectedActionEvent{
+			{action: actionRegister, taskName: "build", eventTaskName: "", event: nil},
+			{action: actionOnEvent, taskName: "build", eventTaskName: "compile", event: &TaskEvent{Status: StatusRunning, ReturnCode: -1}},
+			{action: actionOnEvent, taskName: "build", eventTaskName: "compile", event: &TaskEvent{Status: StatusSuccess, Complete: true, ReturnCode: 0}},
+			{action: actionOnEvent, taskName: "build", eventTaskName: "lint", event: &TaskEvent{Status: StatusRunning, ReturnCode: -1}},
+			{action: actionOnEvent, taskName: "build", eventTaskName: "lint", event: &TaskEvent{Status: StatusError, Complete: true, ReturnCode: 1}},
+			{action: actionOnEvent, taskName: "build", eventTaskName: "package", event: &TaskEvent{Status: StatusRunning, ReturnCode: -1}},
+			{action: actionOnEvent, taskName: "build", eventTaskName: "package", event: &TaskEvent{Status: StatusSuccess, Complete: true, ReturnCode: 0}},
+			{action: actionUnregister, taskName: "compile", eventTaskName: "", event: nil},
+			{action: actionUnregister, taskName: "checks", eventTaskName: "", event: nil},
+			{action: actionUnregister, taskName: "lint", eventTaskName: "", event: nil},
+			{action: actionUnregister, taskName: "package", eventTaskName: "", event: nil},
+			{action: actionUnregister, taskName: "build", eventTaskName: "", event: nil},
+			{action: actionClose, taskName: "", eventTaskName: "", event: nil},
+		},
+		expectedEnv: map[string]string{},
+	}
+
+	runExecutorCase(t, &testCase)
+}
+
+func Test_Executor_run_steps(t *testing.T) {
+	var runYaml = []byte(`
+config:
+  stop-on-failure: false
+tasks:
+  - name: install
+    parallel-tasks:
+      - name: tool
+        steps:
+          - name: download
+            cmd: true
+          - name: extract
+            cmd: false
+          - name: setup
+            cmd: true
+`)
+	var testCase = executorTestCase{
+		runYaml: runYaml,
+		expectedEvents: []expectedActionEvent{
+			{action: actionRegister, taskName: "install", eventTaskName: "", event: nil},
+			{action: actionOnEvent, taskName: "install", eventTaskName: "download", event: &TaskEvent{Status: StatusRunning, ReturnCode: -1}},
+			{action: actionOnEvent, taskName: "install", eventTaskName: "download", event: &TaskEvent{Status: StatusSuccess, Complete: true, ReturnCode: 0}},
+			{action: actionOnEvent, taskName: "install", eventTaskName: "extract", event: &TaskEvent{Status: StatusRunning, ReturnCode: -1}},
+			{action: actionOnEvent, taskName: "install", eventTaskName: "extract", event: &TaskEvent{Status: StatusError, Complete: true, ReturnCode: 1}},
+			{action: actionOnEvent, taskName: "install", eventTaskName: "setup", event: &TaskEvent{Status: StatusSkipped, Complete: true, ReturnCode: -1}},
+			{action: actionUnregister, taskName: "tool", eventTaskName: "", event: nil},
+			{action: actionUnregister, taskName: "download", eventTaskName: "", event: nil},
+			{action: actionUnregister, taskName: "extract", eventTaskName: "", event: nil},
+			{action: actionUnregister, taskName: "setup", eventTaskName: "", event: nil},
+			{action: actionUnregister, taskName: "install", eventTaskName: "", event: nil},
+			{action: actionClose, taskName: "", eventTaskName: "", event: nil},
+		},
+		expectedEnv: map[string]string{},
+	}
+
+	runExecutorCase(t, &testCase)
+}
+
+func Test_Executor_run_needs_order(t *testing.T) {
+	var runYaml = []byte(`
+config:
+  stop-on-failure: false
+  max-parallel-commands: 1
+tasks:
+  - name: easy task 1
+    id: first
+    cmd: true
+  - name: easy task 2
+    needs: third
+    cmd: true
+  - name: easy task 3
+    id: third
+    cmd: true
+`)
+	var testCase = executorTestCase{
+		runYaml: runYaml,
+		expectedEvents: []expectedActionEvent{
+			{action: actionRegister, taskName: "easy task 1", eventTaskName: "", event: nil},
+			{action: actionRegister, taskName: "easy task 3", eventTaskName: "", event: nil},
+			{action: actionOnEvent, taskName: "easy task 1", eventTaskName: "", event: &TaskEvent{Status: StatusRunning, ReturnCode: -1}},
+			{action: actionOnEvent, taskName: "easy task 1", eventTaskName: "", event: &TaskEvent{Status: StatusSuccess, Complete: true, ReturnCode: 0}},
+			{action: actionUnregister, taskName: "easy task 1", eventTaskName: "", event: nil},
+			{action: actionOnEvent, taskName: "easy task 3", eventTaskName: "", event: &TaskEvent{Status: StatusRunning, ReturnCode: -1}},
+			{action: actionOnEvent, taskName: "easy task 3", eventTaskName: "", event: &TaskEvent{Status: StatusSuccess, Complete: true, ReturnCode: 0}},
+			{action: actionUnregister, taskName: "easy task 3", eventTaskName: "", event: nil},
+			{action: actionRegister, taskName: "easy task 2", eventTaskName: "", event: nil},
+			{action: actionOnEvent, taskName: "easy task 2", eventTaskName: "", event: &TaskEvent{Status: StatusRunning, ReturnCode: -1}},
+			{action: actionOnEvent, taskName: "easy task 2", eventTaskName: "", event: &TaskEvent{Status: StatusSuccess, Complete: true, ReturnCode: 0}},
+			{action: actionUnregister, taskName: "easy task 2", eventTaskName: "", event: nil},
+			{action: actionClose, taskName: "", eventTaskName: "", event: nil},
+		},
+		expectedEnv: map[string]string{},
+	}
+
+	runExecutorCase(t, &testCase)
+}
+
+func Test_Executor_run_needs_failedDependency(t *testing.T) {
+	var runYaml = []byte(`
+config:
+  stop-on-failure: false
+tasks:
+  - name: easy task 1
+    id: first
+    cmd: false
+  - name: easy task 2
+    needs: first
+    cmd: true
+`)
+	var testCase = executorTestCase{
+		runYaml: runYaml,
+		expectedEvents: []expectedActionEvent{
+			{action: actionRegister, taskName: "easy task 1", eventTaskName: "", event: nil},
+			{action: actionOnEvent, taskName: "easy task 1", eventTaskName: "", event: &TaskEvent{Status: StatusRunning, ReturnCode: -1}},
+			{action: actionOnEvent, taskName: "easy task 1", eventTaskName: "", event: &TaskEvent{Status: StatusError, Complete: true, ReturnCode: 1}},
+			{action: actionUnregister, taskName: "easy task 1", eventTaskName: "", event: nil},
+			{action: actionRegister, taskName: "easy task 2", eventTaskName: "", event: nil},
+			{action: actionOnEvent, taskName: "easy task 2", eventTaskName: "", event: &TaskEvent{Status: StatusSkipped, Complete: true, ReturnCode: -1}},
+			{action: actionUnregister, taskName: "easy task 2", eventTaskName: "", event: nil},
+			{action: actionClose, taskName: "", eventTaskName: "", event: nil},
+		},
+		expectedEnv: map[string]string{},
+	}
+
+	runExecutorCase(t, &testCase)
+}
+
+func Test_Executor_run_conditions(t *testing.T) {
+	var runYaml = []byte(`
+config:
+  stop-on-failure: false
+tasks:
+  - name: easy task 1
+    id: first
+    cmd: export ANSWER=42
+  - name: easy task 2
+    when: '{{ .Env.ANSWER }} == "42"'
+    cmd: true
+  - name: easy task 3
+    unless: '[ "$ANSWER" = "42" ]'
+    cmd: true
+  - name: easy task 4
+    when: '{{ .Tasks.first }} != success'
+    cmd: true
+`)
+	var testCase = executorTestCase{
+		runYaml: runYaml,
+		expectedEvents: []expectedActionEvent{
+			{action: actionRegister, taskName: "easy task 1", eventTaskName: "", event: nil},
+			{action: actionOnEvent, taskName: "easy task 1", eventTaskName: "", event: &TaskEvent{Status: StatusRunning, ReturnCode: -1}},
+			{action: actionOnEvent, taskName: "easy task 1", eventTaskName: "", event: &TaskEvent{Status: StatusSuccess, Complete: true, ReturnCode: 0}},
+			{action: actionUnregister, taskName: "easy task 1", eventTaskName: "", event: nil},
+			{action: actionRegister, taskName: "easy task 2", eventTaskName: "", event: nil},
+			{action: actionOnEvent, taskName: "easy task 2", eventTaskName: "", event: &TaskEvent{Status: StatusRunning, ReturnCode: -1}},
+			{action: actionOnEvent, taskName: "easy task 2", eventTaskName: "", event: &TaskEvent{Status: StatusSuccess, Complete: true, ReturnCode: 0}},
+			{action: actionUnregister, taskName: "easy task 2", eventTaskName: "", event: nil},
+			{action: actionRegister, taskName: "easy task 3", eventTaskName: "", event: nil},
+			{action: actionOnEvent, taskName: "easy task 3", eventTaskName: "", event: &TaskEvent{Status: StatusSkipped, Complete: true, ReturnCode: -1}},
+			{action: actionUnregister, taskName: "easy task 3", eventTaskName: "", event: nil},
+			{action: actionRegister, taskName: "easy task 4", eventTaskName: "", event: nil},
+			{action: actionOnEvent, taskName: "easy task 4", eventTaskName: "", event: &TaskEvent{Status: StatusSkipped, Complete: true, ReturnCode: -1}},
+			{action: actionUnregister, taskName: "easy task 4", eventTaskName: "", event: nil},
+			{action: actionClose, taskName: "", eventTaskName: "", event: nil},
+		},
+		expectedEnv: map[string]string{},
+	}
+
+	runExecutorCase(t, &testCase)
+}
+
+func Test_Executor_run_conditionsInBackground(t *testing.T) {
+	signalExit(false)
+	runYaml := []byte(`
+tasks:
+  - name: checks
+    parallel-tasks:
+      - name: slow condition
+        when: sleep 1
+        cmd: true
+      - name: hung condition
+        when: sleep 10
+        timeout: 0.3
+        cmd: true
+      - name: unconditional
+        cmd: true
+`)
+
+	cfg, err := config.NewConfig(runYaml, nil)
+	if err != nil {
+		t.Fatalf("config creation failed: %v", err)
+	}
+	executor := newExecutor(cfg)
+	startTime := time.Now()
+	executor.run()
+
+	tasks := make(map[string]*Task)
+	for _, task := range executor.Tasks[0].Children {
+		tasks[task.Config.Name] = task
+	}
+
+	// shell conditions do not hold up any other task
+	if elapsed := tasks["unconditional"].Command.StopTime.Sub(startTime); tasks["unconditional"].Status != StatusSuccess || elapsed > 500*time.Millisecond {
+		t.Errorf("expected the unconditional task to succeed right away, got status=%v after %v", tasks["unconditional"].Status, elapsed)
+	}
+	if tasks["slow condition"].Status != StatusSuccess {
+		t.Errorf("expected the slow condition task to succeed, got status=%v (%s)", tasks["slow condition"].Status, tasks["slow condition"].SkipReason)
+	}
+
+	// shell conditions are limited by the task timeout
+	if tasks["hung condition"].Status != StatusSkipped || tasks["hung condition"].SkipReason != "'when' condition timed out" {
+		t.Errorf("expected the hung condition task to be skipped, got status=%v (%s)", tasks["hung condition"].Status, tasks["hung condition"].SkipReason)
+	}
+	if elapsed := time.Since(startTime); elapsed > 5*time.Second {
+		t.Errorf("expected the hung condition to be stopped by the timeout, took %v", elapsed)
+	}
+}
+
+func Test_Executor_run_hooks_failure(t *testing.T) {
+	var runYaml = []byte(`
+config:
+  stop-on-failure: true
+tasks:
+  - name: easy task 1
+    cmd: false
+    on-failure: export ROLLED_BACK=yes
+  - name: easy task 2
+    cmd: true
+on-failure:
+  - name: notify task
+    cmd: false
+finally:
+  - name: cleanup task
+    cmd: export CLEANED=yes
+`)
+	var testCase = executorTestCase{
+		runYaml: runYaml,
+		expectedEvents: []expectedActionEvent{
+			{action: actionRegister, taskName: "easy task 1", eventTaskName: "", event: nil},
+			{action: actionOnEvent, taskName: "easy task 1", eventTaskName: "", event: &TaskEvent{Status: StatusRunning, ReturnCode: -1}},
+			{action: actionOnEvent, taskName: "easy task 1", eventTaskName: "", event: &TaskEvent{Status: StatusError, Complete: true, ReturnCode: 1}},
+			{action: actionUnregister, taskName: "easy task 1", eventTaskName: "", event: nil},
+			{action: actionRegister, taskName: "easy task 1: export ROLLED_BACK=yes", eventTaskName: "", event: nil},
+			{action: actionOnEvent, taskName: "easy task 1: export ROLLED_BACK=yes", eventTaskName: "", event: &TaskEvent{Status: StatusRunning, ReturnCode: -1}},
+			{action: actionOnEvent, taskName: "easy task 1: export ROLLED_BACK=yes", eventTaskName: "", event: &TaskEvent{Status: StatusSuccess, Complete: true, ReturnCode: 0}},
+			{action: actionUnregister, taskName: "easy task 1: export ROLLED_BACK=yes", eventTaskName: "", event: nil},
+			{action: actionRegister, taskName: "notify task", eventTaskName: "", event: nil},
+			{action: actionOnEvent, taskName: "notify task", eventTaskName: "", event: &TaskEvent{Status: StatusRunning, ReturnCode: -1}},
+			{action: actionOnEvent, taskName: "notify task", eventTaskName: "", event: &TaskEvent{Status: StatusError, Complete: true, ReturnCode: 1}},
+			{action: actionUnregister, taskName: "notify task", eventTaskName: "", event: nil},
+			{action: actionRegister, taskName: "cleanup task", eventTaskName: "", event: nil},
+			{action: actionOnEvent, taskName: "cleanup task", eventTaskName: "", event: &TaskEvent{Status: StatusRunning, ReturnCode: -1}},
+			{action: actionOnEvent, taskName: "cleanup task", eventTaskName: "", event: &TaskEvent{Status: StatusSuccess, Complete: true, ReturnCode: 0}},
+			{action: actionUnregister, taskName: "cleanup task", eventTaskName: "", event: nil},
+			{action: actionClose, taskName: "", eventTaskName: "", event: nil},
+		},
+		expectedEnv: map[string]string{
+			"ROLLED_BACK": "yes",
+			"CLEANED":     "yes",
+		},
+	}
+
+	runExecutorCase(t, &testCase)
+}
+
+func Test_Executor_run_hooks_success(t *testing.T) {
+	var runYaml = []byte(`
+tasks:
+  - name: easy task 1
+    cmd: true
+    on-failure: echo "rolling back"
+on-failure:
+  - name: notify task
+    cmd: echo "failed"
+finally:
+  - name: cleanup task
+    cmd: true
+`)
+	var testCase = executorTestCase{
+		runYaml: runYaml,
+		expectedEvents: []expectedActionEvent{
+			{action: actionRegister, taskName: "easy task 1", eventTaskName: "", event: nil},
+			{action: actionOnEvent, taskName: "easy task 1", eventTaskName: "", event: &TaskEvent{Status: StatusRunning, ReturnCode: -1}},
+			{action: actionOnEvent, taskName: "easy task 1", eventTaskName: "", event: &TaskEvent{Status: StatusSuccess, Complete: true, ReturnCode: 0}},
+			{action: actionUnregister, taskName: "easy task 1", eventTaskName: "", event: nil},
+			{action: actionRegister, taskName: "cleanup task", eventTaskName: "", event: nil},
+			{action: actionOnEvent, taskName: "cleanup task", eventTaskName: "", event: &TaskEvent{Status: StatusRunning, ReturnCode: -1}},
+			{action: actionOnEvent, taskName: "cleanup task", eventTaskName: "", event: &TaskEvent{Status: StatusSuccess, Complete: true, ReturnCode: 0}},
+			{action: actionUnregister, taskName: "cleanup task", eventTaskName: "", event: nil},
+			{action: actionClose, taskName: "", eventTaskName: "", event: nil},
+		},
+		expectedEnv: map[string]string{},
+	}
+
+	runExecutorCase(t, &testCase)
+}
+
+func Test_Executor_run_parallelEnv(t *testing.T) {
+	signalExit(false)
+	runYaml := []byte(`
+tasks:
+  - name: setup task
+    cmd: export BASE=base
+  - name: parallel task
+    cmd: export SHARED=parent
+    parallel-tasks:
+      - name: first child
+        cmd: sleep 0.2; export SHARED=first FIRST="$BASE"
+      - name: second child
+        cmd: export SHARED=second BASE=changed
+      - name: third child
+        cmd: sleep 0.1; export THIRD="$BASE"
+  - name: report task
+    cmd: echo "$BASE $SHARED $FIRST $THIRD"
+    register: REPORT
+`)
+
+	cfg, err := config.NewConfig(runYaml, nil)
+	if err != nil {
+		t.Fatalf("config creation failed: %v", err)
+	}
+	executor := newExecutor(cfg)
+	executor.run()
+
+	// every child is given the same snapshot, while the last declared child wins any conflict (regardless of timing)
+	expected := "changed second base base"
+	if executor.Environment["REPORT"] != expected {
+		t.Errorf("expected report %q, got %q", expected, executor.Environment["REPORT"])
+	}
+
+	// every overridden value is reported as a warning
+	expectedWarnings := []string{
+		"env var 'SHARED' exported by task 'first child' overrides the value exported by task 'parallel task'",
+		"env var 'SHARED' exported by task 'second child' overrides the value exported by task 'first child'",
+	}
+	if !reflect.DeepEqual(executor.Statistics.Warnings, expectedWarnings) {
+		t.Errorf("expected warnings %q, got %q", expectedWarnings, executor.Statistics.Warnings)
+	}
+}
+
+func Test_Executor_run_pools(t *testing.T) {
+	signalExit(false)
+	tempDir, err := ioutil.TempDir("", "bashful-pools")
+	if err != nil {
+		t.Fatalf("unable to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	logFile := filepath.Join(tempDir, "db.log")
+	runYaml := []byte(fmt.Sprintf(`
+config:
+  pools:
+    db: 1
+tasks:
+  - name: migrations
+    id: migrations
+    pool: db
+    parallel-tasks:
+      - cmd: echo start >> %[1]s; sleep 0.1; echo end >> %[1]s
+      - cmd: echo start >> %[1]s; sleep 0.1; echo end >> %[1]s
+  - name: seeds
+    id: seeds
+    parallel-tasks:
+      - cmd: echo start >> %[1]s; sleep 0.1; echo end >> %[1]s
+        pool: db
+      - cmd: echo other
+  - name: report
+    needs: [migrations, seeds]
+    cmd: cat %[1]s
+`, logFile))
+
+	cfg, err := config.NewConfig(runYaml, nil)
+	if err != nil {
+		t.Fatalf("config creation failed: %v", err)
+	}
+	executor := newExecutor(cfg)
+	executor.run()
+
+	if len(executor.Statistics.Failed) > 0 {
+		t.Fatalf("expected no failed tasks, got %d", len(executor.Statistics.Failed))
+	}
+
+	// the pool commands of both parallel groups are never run at the same time
+	contents, err := ioutil.ReadFile(logFile)
+	if err != nil {
+		t.Fatalf("unable to read log: %v", err)
+	}
+	expected := strings.Repeat("start\nend\n", 3)
+	if string(contents) != expected {
+		t.Errorf("expected pool commands to run one at a time, got %q", string(contents))
+	}
+}
+
+func Test_Executor_run_sequentialEnv(t *testing.T) {
+	signalExit(false)
+	runYaml := []byte(`
+tasks:
+  - name: installing
+    tasks:
+      - name: tool
+        steps:
+          - cmd: export ARCHIVE=tool.tgz
+          - cmd: export EXTRACTED="$ARCHIVE extracted"
+          - cmd: echo "installed $EXTRACTED"
+            register: INSTALLED
+  - name: configuring
+    cmd: export BASE=base
+    tasks:
+      - cmd: export CONFIG="$BASE config"
+      - parallel-tasks:
+          - cmd: echo "$CONFIG"
+            register: CONFIGURED
+`)
+
+	cfg, err := config.NewConfig(runYaml, nil)
+	if err != nil {
+		t.Fatalf("config creation failed: %v", err)
+	}
+	executor := newExecutor(cfg)
+	executor.run()
+
+	// every step (and sequential child task) is given the env vars exported by the preceding ones
+	expected := map[string]string{"INSTALLED": "installed tool.tgz extracted", "CONFIGURED": "base config"}
+	for key, value := range expected {
+		if executor.Environment[key] != value {
+			t.Errorf("expected %s=%q, got %q", key, value, executor.Environment[key])
+		}
+	}
+}
+
+func Test_Executor_run_registerSteps(t *testing.T) {
+	signalExit(false)
+	runYaml := []byte(`
+tasks:
+  - name: greeting
+    parallel-tasks:
+      - name: greet
+        steps:
+          - cmd: echo hello
+            register: GREETING
+            register-return-code: true
+          - cmd: test "$GREETING" = hello && test "$GREETING_RC" = 0
+          - cmd: test "{{ .Vars.GREETING }}" = hello
+`)
+
+	cfg, err := config.NewConfig(runYaml, nil)
+	if err != nil {
+		t.Fatalf("config creation failed: %v", err)
+	}
+	executor := newExecutor(cfg)
+	executor.run()
+
+	// a registered value is given to the later steps both as an env var and as a template var
+	for _, step := range executor.Tasks[0].Children[0].Children {
+		if step.Status != StatusSuccess {
+			t.Errorf("expected step '%s' to succeed, got status=%v", step.Config.Name, step.Status)
+		}
+	}
+	if executor.Environment["GREETING"] != "hello" {
+		t.Errorf("expected GREETING=hello, got %q", executor.Environment["GREETING"])
+	}
+}
+
+func Test_Executor_run_resume(t *testing.T) {
+	signalExit(false)
+	tempDir, err := ioutil.TempDir("", "bashful-resume")
+	if err != nil {
+		t.Fatalf("unable to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	readyFile := filepath.Join(tempDir, "ready")
+	runYaml := []byte(fmt.Sprintf(`
+config:
+  stop-on-failure: true
+tasks:
+  - name: easy task 1
+    cmd: export FIRST=yes
+  - name: easy task 2
+    cmd: test -f %s
+  - name: easy task 3
+    cmd: export THIRD=yes
+`, readyFile))
+
+	newTestExecutor := func(resume bool) *Executor {
+		cfg, err := config.NewConfig(runYaml, &config.Cli{YamlPath: "resume.yml", Resume: resume})
+		if err != nil {
+			t.Fatalf("config creation failed: %v", err)
+		}
+		cfg.RunStatePath = filepath.Join(tempDir, "run-state")
+		cfg.EtaCachePath = filepath.Join(tempDir, "eta")
+		return newExecutor(cfg)
+	}
+
+	t.Logf("running (expecting failure)...")
+	executor := newTestExecutor(false)
+	executor.run()
+	if len(executor.Statistics.Failed) != 1 || executor.Statistics.Failed[0].Config.Name != "easy task 2" {
+		t.Fatalf("expected 'easy task 2' to fail")
+	}
+
+	err = ioutil.WriteFile(readyFile, []byte{}, 0644)
+	if err != nil {
+		t.Fatalf("unable to write file: %v", err)
+	}
+
+	t.Logf("resuming...")
+	signalExit(false)
+	executor = newTestExecutor(true)
+	executor.run()
+
+	expectedStatuses := []TaskStatus{StatusSkipped, StatusSuccess, StatusSuccess}
+	for idx, task := range executor.Tasks {
+		if task.Status != expectedStatuses[idx] {
+			t.Errorf("expected task '%s' status=%v, got %v", task.Config.Name, expectedStatuses[idx], task.Status)
+		}
+	}
+	if executor.Tasks[0].SkipReason != resumedReason {
+		t.Errorf("expected skip reason '%s', got '%s'", resumedReason, executor.Tasks[0].SkipReason)
+	}
+
+	for _, key := range []string{"FIRST", "THIRD"} {
+		if executor.Environment[key] != "yes" {
+			t.Errorf("expected env[%s]=yes, got '%s'", key, executor.Environment[key])
+		}
+	}
+
+	if utils.DoesFileExist(filepath.Join(tempDir, "run-state")) {
+		t.Errorf("expected the run state to be removed after a successful run")
+	}
+}
+
+func Test_Executor_run_resumeExports(t *testing.T) {
+	signalExit(false)
+	tempDir, err := ioutil.TempDir("", "bashful-resume-exports")
+	if err != nil {
+		t.Fatalf("unable to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	readyFile := filepath.Join(tempDir, "ready")
+	runYaml := []byte(fmt.Sprintf(`
+config:
+  stop-on-failure: true
+tasks:
+  - name: installing
+    tasks:
+      - name: downloading
+        cmd: export ARCHIVE=tool.tgz
+      - name: checking
+        cmd: test -f %s
+      - name: extracting
+        cmd: echo "extracted $ARCHIVE"
+        register: EXTRACTED
+`, readyFile))
+
+	newTestExecutor := func(resume bool) *Executor {
+		cfg, err := config.NewConfig(runYaml, &config.Cli{YamlPath: "resume.yml", Resume: resume})
+		if err != nil {
+			t.Fatalf("config creation failed: %v", err)
+		}
+		cfg.RunStatePath = filepath.Join(tempDir, "run-state")
+		cfg.EtaCachePath = filepath.Join(tempDir, "eta")
+		return newExecutor(cfg)
+	}
+
+	t.Logf("running (expecting failure)...")
+	executor := newTestExecutor(false)
+	executor.run()
+	if len(executor.Statistics.Failed) != 1 {
+		t.Fatalf("expected 'checking' to fail")
+	}
+
+	err = ioutil.WriteFile(readyFile, []byte{}, 0644)
+	if err != nil {
+		t.Fatalf("unable to write file: %v", err)
+	}
+
+	// the halted top-level task never passed on the exported env vars, which are restored from the resumed task instead
+	t.Logf("resuming...")
+	signalExit(false)
+	executor = newTestExecutor(true)
+	executor.run()
+
+	expected := map[string]string{"ARCHIVE": "tool.tgz", "EXTRACTED": "extracted tool.tgz"}
+	for key, value := range expected {
+		if executor.Environment[key] != value {
+			t.Errorf("expected env[%s]=%q, got %q", key, value, executor.Environment[key])
+		}
+	}
+}
+
+func Test_Executor_run_upToDate(t *testing.T) {
+	tempDir, err := ioutil.TempDir("", "bashful-up-to-date")
+	if err != nil {
+		t.Fatalf("unable to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	inputFile := filepath.Join(tempDir, "input.txt")
+	otherDir := filepath.Join(tempDir, "other")
+	err = os.Mkdir(otherDir, 0755)
+	if err != nil {
+		t.Fatalf("unable to create dir: %v", err)
+	}
+	err = ioutil.WriteFile(filepath.Join(otherDir, "input.txt"), []byte("first"), 0644)
+	if err != nil {
+		t.Fatalf("unable to write file: %v", err)
+	}
+
+	taskYaml := func(cwd, mode string) []byte {
+		return []byte(fmt.Sprintf(`
+tasks:
+  - name: easy task 1
+    cwd: %s
+    cmd: cp input.txt output.txt
+    inputs: "input.*"
+    outputs: output.txt
+    env:
+      MODE: %s
+`, cwd, mode))
+	}
+	runYaml := taskYaml(tempDir, "debug")
+
+	runCase := func(expectedStatus TaskStatus) {
+		signalExit(false)
+		cfg, err := config.NewConfig(runYaml, nil)
+		if err != nil {
+			t.Fatalf("config creation failed: %v", err)
+		}
+		cfg.EtaCachePath = filepath.Join(tempDir, "eta")
+		cfg.FingerprintCachePath = filepath.Join(tempDir, "fingerprints")
+		cfg.RunStatePath = filepath.Join(tempDir, "run-state")
+		executor := newExecutor(cfg)
+		executor.run()
+
+		if executor.Tasks[0].Status != expectedStatus {
+			t.Errorf("expected status=%v, got %v", expectedStatus, executor.Tasks[0].Status)
+		}
+	}
+
+	writeInput := func(contents string) {
+		err := ioutil.WriteFile(inputFile, []byte(contents), 0644)
+		if err != nil {
+			t.Fatalf("unable to write file: %v", err)
+		}
+	}
+
+	writeInput("first")
+
+	t.Logf("Running test case: first run")
+	runCase(StatusSuccess)
+
+	t.Logf("Running test case: unchanged inputs")
+	runCase(StatusUpToDate)
+
+	t.Logf("Running test case: changed inputs")
+	writeInput("second")
+	runCase(StatusSuccess)
+
+	t.Logf("Running test case: missing outputs")
+	os.Remove(filepath.Join(tempDir, "output.txt"))
+	runCase(StatusSuccess)
+
+	t.Logf("Running test case: unchanged inputs (again)")
+	runCase(StatusUpToDate)
+
+	t.Logf("Running test case: same command in another cwd")
+	runYaml = taskYaml(otherDir, "debug")
+	runCase(StatusSuccess)
+
+	t.Logf("Running test case: changed env")
+	runYaml = taskYaml(tempDir, "release")
+	runCase(StatusSuccess)
+
+	t.Logf("Running test case: unchanged env (again)")
+	runCase(StatusUpToDate)
+}
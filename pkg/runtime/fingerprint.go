@@ -0,0 +1,157 @@
+// Copyright © 2018 Alex Goodman
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package runtime
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"github.com/wagoodman/bashful/pkg/log"
+	"github.com/wagoodman/bashful/utils"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// readFingerprintCache reads the fingerprint cache of all tasks that have previously succeeded (see fingerprintCacheKey)
+func (executor *Executor) readFingerprintCache() {
+	executor.fingerprintCache = make(map[string]string)
+	if utils.DoesFileExist(executor.config.FingerprintCachePath) {
+		err := utils.Load(executor.config.FingerprintCachePath, &executor.fingerprintCache)
+		if err != nil {
+			log.LogToMain(fmt.Sprintf("unable to load fingerprint cache: %v", err), log.StyleError)
+		}
+	}
+}
+
+// tracksInputs indicates if the Task declares any inputs or outputs (and may be considered up to date)
+func (task *Task) tracksInputs() bool {
+	return len(task.Config.Inputs) > 0 || len(task.Config.Outputs) > 0
+}
+
+// fingerprintCacheKey returns the key of the Task in the fingerprint cache: a hash of the Task name and id, the working
+// directory, the command, and the env vars given to the command by bashful
+func (task *Task) fingerprintCacheKey(environment map[string]string) string {
+	hash := sha256.New()
+	fmt.Fprintf(hash, "name:%s\x00id:%s\x00cwd:%s\x00cmd:%s\x00", task.Config.Name, task.Config.Id, task.Config.CwdString, task.Config.CmdString)
+
+	resolved := make(map[string]string, len(environment)+len(task.Config.Env))
+	for key, value := range environment {
+		resolved[key] = value
+	}
+	for key, value := range task.Config.Env {
+		resolved[key] = value
+	}
+	keys := make([]string, 0, len(resolved))
+	for key := range resolved {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		fmt.Fprintf(hash, "env:%s=%s\x00", key, resolved[key])
+	}
+
+	return fmt.Sprintf("%x", hash.Sum(nil))
+}
+
+// upToDate indicates if the given Task has previously succeeded with the same inputs (matching the given previous
+// fingerprint) and all outputs still exist. The fingerprint of the current inputs is kept on the Task, to be cached once
+// the Task has succeeded. Since all inputs are read, this is called from the goroutine running the Task (not the Executor).
+func (task *Task) upToDate(previous string) bool {
+	fingerprint, err := task.inputFingerprint()
+	if err != nil {
+		log.LogToMain(fmt.Sprintf("unable to fingerprint inputs of task '%s': %v", task.Config.Name, err), log.StyleError)
+		return false
+	}
+	task.fingerprint = fingerprint
+
+	if previous == "" || previous != fingerprint {
+		return false
+	}
+
+	for _, output := range task.Config.Outputs {
+		if !utils.DoesFileExist(task.resolvePath(output)) {
+			return false
+		}
+	}
+	return true
+}
+
+// resolvePath returns the given path relative to the Task working directory
+func (task *Task) resolvePath(path string) string {
+	if filepath.IsAbs(path) || task.Config.CwdString == "" {
+		return path
+	}
+	return filepath.Join(task.Config.CwdString, path)
+}
+
+// inputFingerprint returns a hash of the Task command, the declared outputs, and the path and contents of every
+// file matching the input globs (matching directories are included recursively)
+func (task *Task) inputFingerprint() (string, error) {
+	hash := sha256.New()
+	fmt.Fprintf(hash, "cmd:%s\x00", task.Config.CmdString)
+	for _, output := range task.Config.Outputs {
+		fmt.Fprintf(hash, "output:%s\x00", output)
+	}
+
+	files := make(map[string]bool)
+	for _, pattern := range task.Config.Inputs {
+		matches, err := filepath.Glob(task.resolvePath(pattern))
+		if err != nil {
+			return "", err
+		}
+		for _, match := range matches {
+			err = filepath.Walk(match, func(path string, info os.FileInfo, err error) error {
+				if err != nil {
+					return err
+				}
+				if info.Mode().IsRegular() {
+					files[path] = true
+				}
+				return nil
+			})
+			if err != nil {
+				return "", err
+			}
+		}
+	}
+
+	paths := make([]string, 0, len(files))
+	for path := range files {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+
+	for _, path := range paths {
+		fmt.Fprintf(hash, "input:%s\x00", path)
+		file, err := os.Open(path)
+		if err != nil {
+			return "", err
+		}
+		_, err = io.Copy(hash, file)
+		file.Close()
+		if err != nil {
+			return "", err
+		}
+	}
+
+	return fmt.Sprintf("%x", hash.Sum(nil)), nil
+}
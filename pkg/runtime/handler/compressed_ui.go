@@ -66,7 +66,7 @@ func (handler *CompressedUI) doRegister(task *runtime.Task) {
 	handler.data[task.Id] = &cUiData{
 		Task: task,
 	}
-	for _, subTask := range task.Children {
+	for _, subTask := range task.Descendants() {
 		handler.data[subTask.Id] = &cUiData{
 			Task: subTask,
 		}
@@ -126,6 +126,15 @@ func (handler *CompressedUI) displayTask(task *runtime.Task) {
 
 	if handler.config.Options.ShowSummarySteps {
 		stepString = fmt.Sprintf(" Tasks[%d/%d]", len(handler.runtimeData.Completed), handler.runtimeData.Total)
+		if len(handler.runtimeData.Skipped) > 0 {
+			stepString += fmt.Sprintf(" Skipped[%d]", len(handler.runtimeData.Skipped))
+		}
+		if len(handler.runtimeData.UpToDate) > 0 {
+			stepString += fmt.Sprintf(" UpToDate[%d]", len(handler.runtimeData.UpToDate))
+		}
+		if len(handler.runtimeData.Killed) > 0 {
+			stepString += fmt.Sprintf(" Killed[%d]", len(handler.runtimeData.Killed))
+		}
 	}
 
 	if handler.config.Options.ShowSummaryErrors {
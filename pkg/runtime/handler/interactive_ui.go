@@ -0,0 +1,453 @@
+package handler
+
+import (
+	"bytes"
+	"fmt"
+	color "github.com/mgutz/ansi"
+	"github.com/wagoodman/bashful/pkg/config"
+	"github.com/wagoodman/bashful/pkg/runtime"
+	"github.com/wagoodman/bashful/utils"
+	"github.com/wagoodman/jotframe"
+	"github.com/wayneashleyberry/terminal-dimensions"
+	"golang.org/x/sys/unix"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+const (
+	keyUp       = "\x1b[A"
+	keyDown     = "\x1b[B"
+	keyPageUp   = "\x1b[5~"
+	keyPageDown = "\x1b[6~"
+	keyHome     = "\x1b[H"
+	keyEnd      = "\x1b[F"
+	keyEscape   = "\x1b"
+	keyEnter    = "\n"
+
+	// keyPollInterval is how often stdin is checked for any pressed keys
+	keyPollInterval = 50 * time.Millisecond
+
+	// maxPaneLines is the number of most recent output lines kept per task for showing in the pane
+	maxPaneLines = 5000
+)
+
+// outputPane is a scrollable (full screen) view of all output lines of a single task
+type outputPane struct {
+	task *runtime.Task
+
+	// offset is the index of the first output line shown (-1 follows the latest output)
+	offset int
+}
+
+// NewInteractiveUI creates a vertical UI where the arrow keys select a task line and enter shows a scrollable pane of
+// all task output (escape or 'q' returns to the task lines). Tasks keep running while the pane is shown. The selected
+// task can be killed ('x'), skipped ('s'), or retried ('r') by the given executor.
+func NewInteractiveUI(cfg *config.Config, executor *runtime.Executor) *VerticalUI {
+	handler := NewVerticalUI(cfg)
+	handler.interactive = true
+	handler.executor = executor
+	return handler
+}
+
+// startInput puts the terminal into a non-canonical mode without echo and starts reading keys (if stdin is a terminal)
+func (handler *VerticalUI) startInput() {
+	if handler.terminalState != nil {
+		return
+	}
+
+	state, err := unix.IoctlGetTermios(int(os.Stdin.Fd()), ioctlReadTermios)
+	if err != nil {
+		// stdin is not a terminal, there is nothing to read keys from
+		handler.interactive = false
+		return
+	}
+	handler.terminalState = state
+
+	termios := *state
+	termios.Lflag &^= unix.ECHO | unix.ICANON
+	termios.Cc[unix.VMIN] = 1
+	termios.Cc[unix.VTIME] = 0
+	unix.IoctlSetTermios(int(os.Stdin.Fd()), ioctlWriteTermios, &termios)
+
+	utils.OnExit(handler.restoreTerminal)
+
+	go handler.inputHandler()
+}
+
+// stopInput stops reading keys and restores the terminal to the original state
+func (handler *VerticalUI) stopInput() {
+	if handler.terminalState == nil {
+		return
+	}
+	close(handler.done)
+
+	// wait for any in-flight read
+	handler.input.Lock()
+	defer handler.input.Unlock()
+
+	handler.restoreTerminal()
+}
+
+// restoreTerminal restores the original terminal state (leaving the pane screen if shown)
+func (handler *VerticalUI) restoreTerminal() {
+	if handler.pane != nil {
+		fmt.Print("\x1b[?1049l")
+	}
+	unix.IoctlSetTermios(int(os.Stdin.Fd()), ioctlWriteTermios, handler.terminalState)
+}
+
+// inputHandler periodically reads all pressed keys from stdin until the handler is closed. The keys are handled by the
+// executor (between events), since handling a key reads the state of the tasks.
+func (handler *VerticalUI) inputHandler() {
+	ticker := time.NewTicker(keyPollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-handler.done:
+			return
+		case <-ticker.C:
+			keys := handler.readKeys()
+			if len(keys) == 0 {
+				continue
+			}
+
+			handler.executor.Invoke(func() {
+				handler.lock.Lock()
+				defer handler.lock.Unlock()
+
+				for _, key := range keys {
+					handler.onKey(key)
+				}
+			})
+		}
+	}
+}
+
+// readKeys reads (without blocking) all keys pressed since the last read
+func (handler *VerticalUI) readKeys() []string {
+	handler.input.Lock()
+	defer handler.input.Unlock()
+
+	fd := int(os.Stdin.Fd())
+	buffer := make([]byte, 256)
+
+	// stdin must only be non-blocking during the read, since jotframe reads from stdin as well
+	syscall.SetNonblock(fd, true)
+	count, err := syscall.Read(fd, buffer)
+	syscall.SetNonblock(fd, false)
+	if err != nil || count <= 0 {
+		return nil
+	}
+
+	return splitKeys(string(buffer[:count]))
+}
+
+// splitKeys splits the given input into single key presses (a single character or an escape sequence)
+func splitKeys(input string) []string {
+	var keys []string
+	for len(input) > 0 {
+		length := 1
+		if strings.HasPrefix(input, "\x1b[") {
+			// an escape sequence ends with the first letter or '~'
+			length = strings.IndexAny(input[2:], "ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz~")
+			if length < 0 {
+				length = len(input)
+			} else {
+				length += 3
+			}
+		}
+		keys = append(keys, input[:length])
+		input = input[length:]
+	}
+	return keys
+}
+
+// onKey updates the selected task line or the pane for the given key
+func (handler *VerticalUI) onKey(key string) {
+	if handler.pane != nil {
+		switch key {
+		case keyUp, "k":
+			handler.scrollPane(-1)
+		case keyDown, "j":
+			handler.scrollPane(1)
+		case keyPageUp:
+			handler.scrollPane(-handler.paneHeight())
+		case keyPageDown, " ":
+			handler.scrollPane(handler.paneHeight())
+		case keyHome, "g":
+			handler.pane.offset = 0
+			handler.drawPane()
+		case keyEnd, "G":
+			handler.pane.offset = -1
+			handler.drawPane()
+		case keyEscape, "q":
+			handler.closePane()
+		}
+		return
+	}
+
+	switch key {
+	case keyUp, "k":
+		handler.selectLine(-1)
+	case keyDown, "j":
+		handler.selectLine(1)
+	case keyEscape:
+		handler.selectLine(0)
+	}
+
+	if handler.selected < 0 || handler.selected >= len(handler.selectable) {
+		return
+	}
+	task := handler.selectable[handler.selected].Task
+
+	// killing or retrying a task with steps applies to the current step (while skipping applies to all steps)
+	command := task
+	if len(task.Config.Steps) > 0 {
+		command, _ = currentStep(task)
+	}
+
+	switch key {
+	case keyEnter:
+		handler.openPane(task)
+	case "x":
+		handler.executor.Control(command, runtime.ActionKill)
+	case "s":
+		handler.executor.Control(task, runtime.ActionSkip)
+	case "r":
+		handler.executor.Control(command, runtime.ActionRetry)
+	}
+}
+
+// selectLine moves the selection by the given number of task lines (zero removes the selection)
+func (handler *VerticalUI) selectLine(delta int) {
+	if len(handler.selectable) == 0 {
+		return
+	}
+
+	previous := handler.selected
+	switch {
+	case delta == 0:
+		handler.selected = -1
+	case handler.selected < 0 && delta < 0:
+		handler.selected = len(handler.selectable) - 1
+	case handler.selected < 0:
+		handler.selected = 0
+	default:
+		handler.selected += delta
+		if handler.selected < 0 {
+			handler.selected = 0
+		}
+		if handler.selected >= len(handler.selectable) {
+			handler.selected = len(handler.selectable) - 1
+		}
+	}
+
+	for _, index := range []int{previous, handler.selected} {
+		if index >= 0 && index < len(handler.selectable) {
+			handler.drawLine(handler.selectable[index])
+		}
+	}
+}
+
+// removeSelectable removes the task lines drawn on the given (removed) jotframe lines from the selection
+func (handler *VerticalUI) removeSelectable(lines []*jotframe.Line) {
+	var selected *display
+	if handler.selected >= 0 && handler.selected < len(handler.selectable) {
+		selected = handler.selectable[handler.selected]
+	}
+
+	remaining := make([]*display, 0, len(handler.selectable))
+	for _, displayData := range handler.selectable {
+		removed := false
+		for _, line := range lines {
+			if displayData.line == line {
+				removed = true
+				break
+			}
+		}
+		if !removed {
+			remaining = append(remaining, displayData)
+		}
+	}
+	handler.selectable = remaining
+
+	handler.selected = -1
+	for index, displayData := range handler.selectable {
+		if displayData == selected {
+			handler.selected = index
+		}
+	}
+}
+
+// collectOutput keeps the stdout/stderr line of the given event for showing in the pane (the output of all steps is shown
+// together). Only the last maxPaneLines lines of each task are kept.
+func (handler *VerticalUI) collectOutput(e runtime.TaskEvent) {
+	id := lineTask(e.Task).Id
+	for _, line := range []string{e.Stdout, e.Stderr} {
+		if line != "" {
+			handler.output[id] = append(handler.output[id], line)
+		}
+	}
+
+	discard := len(handler.output[id]) - maxPaneLines
+	if discard <= 0 {
+		return
+	}
+	handler.output[id] = handler.output[id][discard:]
+	handler.discarded[id] += discard
+
+	// keep showing the same lines in a scrolled pane
+	if handler.pane != nil && handler.pane.task.Id == id && handler.pane.offset >= 0 {
+		handler.pane.offset -= discard
+		if handler.pane.offset < 0 {
+			handler.pane.offset = 0
+		}
+	}
+}
+
+// openPane shows all output of the given task (in the alternate screen buffer, leaving the frame untouched)
+func (handler *VerticalUI) openPane(task *runtime.Task) {
+	handler.pane = &outputPane{task: task, offset: -1}
+	fmt.Print("\x1b[?1049h")
+	handler.drawPane()
+}
+
+// closePane returns to the frame, registering/unregistering any tasks that were started/finished in the meantime
+func (handler *VerticalUI) closePane() {
+	if handler.pane == nil {
+		return
+	}
+	handler.pane = nil
+	fmt.Print("\x1b[?1049l")
+
+	deferred := handler.deferred
+	handler.deferred = nil
+	for _, fn := range deferred {
+		fn()
+	}
+
+	// all events while the pane was shown have not been drawn
+	for _, displayData := range handler.selectable {
+		task := displayData.Task
+		if task.Started {
+			displayData.Values.Status = handler.TaskStatusColor(task.Status, "i")
+		}
+		handler.drawLine(displayData)
+		handler.displayOutputLines(displayData)
+	}
+
+	if handler.config.Options.ShowSummaryFooter && handler.frame != nil {
+		io.WriteString(handler.frame.Footer(), handler.footer(runtime.StatusPending, ""))
+	}
+}
+
+// paneHeight is the number of output lines that fit in the pane
+func (handler *VerticalUI) paneHeight() int {
+	terminalHeight, _ := terminaldimensions.Height()
+	height := int(terminalHeight) - 3
+	if height < 1 {
+		height = 1
+	}
+	return height
+}
+
+// scrollPane moves the shown output lines by the given number of lines
+func (handler *VerticalUI) scrollPane(delta int) {
+	lines := len(handler.output[handler.pane.task.Id])
+	height := handler.paneHeight()
+	last := lines - height
+	if last < 0 {
+		last = 0
+	}
+
+	offset := handler.pane.offset
+	if offset < 0 {
+		offset = last
+	}
+	offset += delta
+	if offset < 0 {
+		offset = 0
+	}
+
+	// scrolling to the end follows any new output
+	if offset >= last {
+		offset = -1
+	}
+	handler.pane.offset = offset
+	handler.drawPane()
+}
+
+// drawPane renders the task title, status, and a window of output lines to the entire screen
+func (handler *VerticalUI) drawPane() {
+	task := handler.pane.task
+	title := task.Config.Name
+	lines := handler.output[task.Id]
+	height := handler.paneHeight()
+	terminalWidth, _ := terminaldimensions.Width()
+	width := int(terminalWidth)
+
+	start := handler.pane.offset
+	if start < 0 {
+		start = len(lines) - height
+	}
+	if start < 0 {
+		start = 0
+	}
+	stop := start + height
+	if stop > len(lines) {
+		stop = len(lines)
+	}
+
+	// a task with steps is shown as the current step
+	command := task
+	if len(task.Config.Steps) > 0 {
+		var number int
+		command, number = currentStep(task)
+		title += fmt.Sprintf(" (step %d/%d: %s)", number, len(task.Children), command.Config.Name)
+	}
+
+	status := command.Status
+	description := command.Status.String()
+	if !command.Started {
+		status = runtime.StatusPending
+		description = status.String()
+		if command.WaitingOnPool != "" {
+			description += " (waiting on pool '" + command.WaitingOnPool + "')"
+		}
+	} else if !command.Completed {
+		status = runtime.StatusRunning
+		description = status.String()
+	} else if command.Command.ReturnCode >= 0 {
+		description += " (return code " + strconv.Itoa(command.Command.ReturnCode) + ")"
+	}
+	discarded := handler.discarded[task.Id]
+	position := fmt.Sprintf("lines %d-%d of %d", discarded+start+1, discarded+stop, discarded+len(lines))
+	if discarded > 0 {
+		position += fmt.Sprintf(" (only the last %d lines are kept)", maxPaneLines)
+	}
+	if len(lines) == 0 {
+		position = "no output"
+	}
+
+	var buffer bytes.Buffer
+	buffer.WriteString("\x1b[H\x1b[2J")
+	buffer.WriteString(" " + handler.TaskStatusColor(status, "i") + "  " + color.Reset + " " + utils.Bold(title) + " " + description + " " + utils.Purple(position) + "\r\n")
+	buffer.WriteString(strings.Repeat("─", width) + "\r\n")
+	for _, line := range lines[start:stop] {
+		if utils.VisualLength(line) > width {
+			line = utils.TrimToVisualLength(line, width)
+		}
+		buffer.WriteString(line + color.Reset + "\r\n")
+	}
+	for row := stop - start; row < height; row++ {
+		buffer.WriteString("\r\n")
+	}
+	buffer.WriteString(utils.Bold(" ↑/↓ scroll  PgUp/PgDn page  g/G top/bottom  q/esc back to all tasks"))
+
+	os.Stdout.Write(buffer.Bytes())
+}
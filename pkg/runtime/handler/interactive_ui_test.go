@@ -0,0 +1,35 @@
+package handler
+
+import (
+	"fmt"
+	"github.com/wagoodman/bashful/pkg/config"
+	"github.com/wagoodman/bashful/pkg/runtime"
+	"testing"
+)
+
+func Test_VerticalUI_collectOutput(t *testing.T) {
+	handler := NewVerticalUI(&config.Config{})
+	defer handler.ticker.Stop()
+
+	task := newTestTask("00000000-0000-0000-0000-000000000001", config.TaskConfig{Name: "build", CmdString: "make"})
+	handler.pane = &outputPane{task: task, offset: 10}
+
+	total := maxPaneLines + 25
+	for index := 0; index < total; index++ {
+		handler.collectOutput(runtime.TaskEvent{Task: task, Status: runtime.StatusRunning, Stdout: fmt.Sprintf("line %d", index), ReturnCode: -1})
+	}
+
+	lines := handler.output[task.Id]
+	if len(lines) != maxPaneLines {
+		t.Errorf("expected %d kept lines, got %d", maxPaneLines, len(lines))
+	}
+	if lines[0] != "line 25" || lines[len(lines)-1] != fmt.Sprintf("line %d", total-1) {
+		t.Errorf("expected the most recent lines to be kept, got %q ... %q", lines[0], lines[len(lines)-1])
+	}
+	if handler.discarded[task.Id] != 25 {
+		t.Errorf("expected 25 discarded lines, got %d", handler.discarded[task.Id])
+	}
+	if handler.pane.offset != 0 {
+		t.Errorf("expected the scrolled pane to stay on the oldest kept line, got offset %d", handler.pane.offset)
+	}
+}
@@ -0,0 +1,140 @@
+package handler
+
+import (
+	"encoding/json"
+	"github.com/lunixbochs/vtclean"
+	"github.com/wagoodman/bashful/pkg/runtime"
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+// JsonStream writes every task event as a single line of json (json-lines) for consumption by other tools
+type JsonStream struct {
+	lock    sync.Mutex
+	writer  io.Writer
+	encoder *json.Encoder
+}
+
+// jsonEvent is the json representation of a single task event
+type jsonEvent struct {
+	// Time is when the event was received
+	Time time.Time `json:"time"`
+
+	// TaskId is the unique runtime id of the task the event is for
+	TaskId string `json:"task-id"`
+
+	// Id is the user given id of the task (if any)
+	Id string `json:"id,omitempty"`
+
+	// Name is the display name of the task the event is for
+	Name string `json:"name"`
+
+	// ParentTaskId and ParentName identify the top-level task of a child task event
+	ParentTaskId string `json:"parent-task-id,omitempty"`
+	ParentName   string `json:"parent-name,omitempty"`
+
+	// Status is the pending/running/error/success/... status of the task command
+	Status string `json:"status"`
+
+	// Stdout and Stderr are a single line of command output (without any ansi sequences)
+	Stdout string `json:"stdout,omitempty"`
+	Stderr string `json:"stderr,omitempty"`
+
+	// Complete indicates that the task command has exited (or was not run at all)
+	Complete bool `json:"complete"`
+
+	// ReturnCode is the command return code (only meaningful when complete)
+	ReturnCode int `json:"return-code"`
+
+	// Attempt is the (1-based) number of times the task command has been run
+	Attempt int `json:"attempt,omitempty"`
+
+	// StartTime and StopTime indicate when the task command was started and exited
+	StartTime *time.Time `json:"start-time,omitempty"`
+	StopTime  *time.Time `json:"stop-time,omitempty"`
+
+	// Reason describes why the task was skipped, why the task has failed (beyond a non-zero return code), or which pool the task is waiting on
+	Reason string `json:"reason,omitempty"`
+}
+
+// NewJsonStream creates a handler that writes json-lines to the given writer (which is closed with the handler, unless it is stdout/stderr)
+func NewJsonStream(writer io.Writer) *JsonStream {
+	encoder := json.NewEncoder(writer)
+	encoder.SetEscapeHTML(false)
+
+	return &JsonStream{
+		writer:  writer,
+		encoder: encoder,
+	}
+}
+
+func (handler *JsonStream) AddRuntimeData(data *runtime.TaskStatistics) {
+
+}
+
+func (handler *JsonStream) Register(task *runtime.Task) {
+
+}
+
+func (handler *JsonStream) Unregister(task *runtime.Task) {
+
+}
+
+func (handler *JsonStream) OnEvent(task *runtime.Task, e runtime.TaskEvent) {
+	handler.lock.Lock()
+	defer handler.lock.Unlock()
+
+	event := jsonEvent{
+		Time:       time.Now(),
+		TaskId:     e.Task.Id.String(),
+		Id:         e.Task.Config.Id,
+		Name:       e.Task.Config.Name,
+		Status:     e.Status.String(),
+		Stdout:     vtclean.Clean(e.Stdout, false),
+		Stderr:     vtclean.Clean(e.Stderr, false),
+		Complete:   e.Complete,
+		ReturnCode: e.ReturnCode,
+		Attempt:    e.Attempt,
+	}
+
+	if task != e.Task {
+		event.ParentTaskId = task.Id.String()
+		event.ParentName = task.Config.Name
+	}
+
+	if !e.StartTime.IsZero() {
+		startTime := e.StartTime
+		event.StartTime = &startTime
+	}
+
+	if e.Complete {
+		if !e.StopTime.IsZero() {
+			stopTime := e.StopTime
+			event.StopTime = &stopTime
+		}
+		if e.Status == runtime.StatusSkipped {
+			event.Reason = e.Task.SkipReason
+		} else {
+			event.Reason = e.Task.Command.FailureReason
+		}
+	} else if e.Task.WaitingOnPool != "" {
+		event.Reason = "waiting on pool '" + e.Task.WaitingOnPool + "'"
+	}
+
+	// todo: check err
+	handler.encoder.Encode(event)
+}
+
+func (handler *JsonStream) Close() {
+	handler.lock.Lock()
+	defer handler.lock.Unlock()
+
+	if handler.writer == os.Stdout || handler.writer == os.Stderr {
+		return
+	}
+	if closer, ok := handler.writer.(io.Closer); ok {
+		closer.Close()
+	}
+}
@@ -0,0 +1,92 @@
+package handler
+
+import (
+	"bytes"
+	"github.com/google/uuid"
+	"github.com/wagoodman/bashful/pkg/config"
+	"github.com/wagoodman/bashful/pkg/runtime"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+)
+
+var eventTimePattern = regexp.MustCompile(`"time":"[^"]+"`)
+
+func newTestTask(id string, taskConfig config.TaskConfig) *runtime.Task {
+	task := runtime.NewTask(taskConfig, &config.Options{})
+	task.Id = uuid.Must(uuid.Parse(id))
+	for index, subTask := range task.Children {
+		subTask.Id = uuid.Must(uuid.Parse(id[:len(id)-1] + string(rune('a'+index))))
+	}
+	return task
+}
+
+func Test_JsonStream_OnEvent(t *testing.T) {
+	startTime := time.Date(2018, 1, 2, 3, 4, 5, 0, time.UTC)
+	stopTime := startTime.Add(1500 * time.Millisecond)
+
+	table := map[string]struct {
+		event    func() (*runtime.Task, runtime.TaskEvent)
+		expected string
+	}{
+		"running task output": {
+			event: func() (*runtime.Task, runtime.TaskEvent) {
+				task := newTestTask("00000000-0000-0000-0000-000000000001", config.TaskConfig{Name: "build", Id: "build-id", CmdString: "make"})
+				return task, runtime.TaskEvent{Task: task, Status: runtime.StatusRunning, Stdout: "\x1b[31mcompiling\x1b[0m", ReturnCode: -1, Attempt: 1, StartTime: startTime}
+			},
+			expected: `{"time":"<time>","task-id":"00000000-0000-0000-0000-000000000001","id":"build-id","name":"build","status":"running","stdout":"compiling","complete":false,"return-code":-1,"attempt":1,"start-time":"2018-01-02T03:04:05Z"}`,
+		},
+
+		"failed child task": {
+			event: func() (*runtime.Task, runtime.TaskEvent) {
+				task := newTestTask("00000000-0000-0000-0000-000000000002", config.TaskConfig{Name: "checks", ParallelTasks: []config.TaskConfig{{Name: "lint", CmdString: "lint <all>"}}})
+				child := task.Children[0]
+				child.Command.FailureReason = "output matched 'FAIL'"
+				return task, runtime.TaskEvent{Task: child, Status: runtime.StatusError, Stderr: "FAIL", Complete: true, ReturnCode: 2, Attempt: 2, StartTime: startTime, StopTime: stopTime}
+			},
+			expected: `{"time":"<time>","task-id":"00000000-0000-0000-0000-00000000000a","name":"lint","parent-task-id":"00000000-0000-0000-0000-000000000002","parent-name":"checks","status":"error","stderr":"FAIL","complete":true,"return-code":2,"attempt":2,"start-time":"2018-01-02T03:04:05Z","stop-time":"2018-01-02T03:04:06.5Z","reason":"output matched 'FAIL'"}`,
+		},
+
+		"skipped task": {
+			event: func() (*runtime.Task, runtime.TaskEvent) {
+				task := newTestTask("00000000-0000-0000-0000-000000000003", config.TaskConfig{Name: "deploy", CmdString: "./deploy.sh"})
+				task.SkipReason = "needed task 'build' did not succeed"
+				return task, runtime.TaskEvent{Task: task, Status: runtime.StatusSkipped, Complete: true, ReturnCode: 0}
+			},
+			expected: `{"time":"<time>","task-id":"00000000-0000-0000-0000-000000000003","name":"deploy","status":"skipped","complete":true,"return-code":0,"reason":"needed task 'build' did not succeed"}`,
+		},
+
+		"task waiting on a pool": {
+			event: func() (*runtime.Task, runtime.TaskEvent) {
+				task := newTestTask("00000000-0000-0000-0000-000000000004", config.TaskConfig{Name: "migrate", CmdString: "./migrate.sh"})
+				task.WaitingOnPool = "database"
+				return task, runtime.TaskEvent{Task: task, Status: runtime.StatusPending, ReturnCode: -1}
+			},
+			expected: `{"time":"<time>","task-id":"00000000-0000-0000-0000-000000000004","name":"migrate","status":"pending","complete":false,"return-code":-1,"reason":"waiting on pool 'database'"}`,
+		},
+	}
+
+	for name, testCase := range table {
+		t.Logf("Running test case: %s", name)
+
+		var buffer bytes.Buffer
+		handler := NewJsonStream(&buffer)
+		handler.OnEvent(testCase.event())
+		handler.Close()
+
+		lines := strings.Split(strings.TrimSuffix(buffer.String(), "\n"), "\n")
+		if len(lines) != 1 {
+			t.Errorf("[case: %s] expected a single json line, got %d: %q", name, len(lines), buffer.String())
+			continue
+		}
+		if !eventTimePattern.MatchString(lines[0]) {
+			t.Errorf("[case: %s] expected an event time, got: %s", name, lines[0])
+		}
+
+		actual := eventTimePattern.ReplaceAllString(lines[0], `"time":"<time>"`)
+		if actual != testCase.expected {
+			t.Errorf("[case: %s] expected json line:\n%s\ngot:\n%s", name, testCase.expected, actual)
+		}
+	}
+}
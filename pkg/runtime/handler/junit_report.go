@@ -0,0 +1,207 @@
+package handler
+
+import (
+	"encoding/xml"
+	"fmt"
+	"github.com/google/uuid"
+	"github.com/lunixbochs/vtclean"
+	"github.com/wagoodman/bashful/pkg/log"
+	"github.com/wagoodman/bashful/pkg/runtime"
+	"io/ioutil"
+	"sync"
+	"time"
+)
+
+// JUnitReport writes a JUnit XML report of all tasks (a testsuite per top-level task) when closed
+type JUnitReport struct {
+	lock  sync.Mutex
+	path  string
+	tasks []*runtime.Task
+
+	// taskIndex maps each registered top-level task id to its index in tasks (a task registered again, e.g. when retried, replaces its entry)
+	taskIndex map[uuid.UUID]int
+}
+
+type junitTestSuites struct {
+	XMLName  xml.Name         `xml:"testsuites"`
+	Tests    int              `xml:"tests,attr"`
+	Failures int              `xml:"failures,attr"`
+	Skipped  int              `xml:"skipped,attr"`
+	Time     string           `xml:"time,attr"`
+	Suites   []junitTestSuite `xml:"testsuite"`
+}
+
+type junitTestSuite struct {
+	Name      string          `xml:"name,attr"`
+	Tests     int             `xml:"tests,attr"`
+	Failures  int             `xml:"failures,attr"`
+	Skipped   int             `xml:"skipped,attr"`
+	Time      string          `xml:"time,attr"`
+	Timestamp string          `xml:"timestamp,attr,omitempty"`
+	Cases     []junitTestCase `xml:"testcase"`
+}
+
+type junitTestCase struct {
+	Name      string        `xml:"name,attr"`
+	ClassName string        `xml:"classname,attr"`
+	Time      string        `xml:"time,attr"`
+	Failure   *junitFailure `xml:"failure,omitempty"`
+	Skipped   *junitSkipped `xml:"skipped,omitempty"`
+}
+
+type junitFailure struct {
+	Message  string `xml:"message,attr"`
+	Type     string `xml:"type,attr"`
+	Contents string `xml:",chardata"`
+}
+
+type junitSkipped struct {
+	Message string `xml:"message,attr,omitempty"`
+}
+
+// NewJUnitReport creates a handler that writes a JUnit XML report to the given path
+func NewJUnitReport(path string) *JUnitReport {
+	return &JUnitReport{
+		path:      path,
+		tasks:     make([]*runtime.Task, 0),
+		taskIndex: make(map[uuid.UUID]int),
+	}
+}
+
+func (handler *JUnitReport) AddRuntimeData(data *runtime.TaskStatistics) {
+
+}
+
+func (handler *JUnitReport) Register(task *runtime.Task) {
+	handler.lock.Lock()
+	defer handler.lock.Unlock()
+
+	if index, exists := handler.taskIndex[task.Id]; exists {
+		handler.tasks[index] = task
+		return
+	}
+	handler.taskIndex[task.Id] = len(handler.tasks)
+	handler.tasks = append(handler.tasks, task)
+}
+
+func (handler *JUnitReport) Unregister(task *runtime.Task) {
+
+}
+
+func (handler *JUnitReport) OnEvent(task *runtime.Task, e runtime.TaskEvent) {
+
+}
+
+func (handler *JUnitReport) Close() {
+	handler.lock.Lock()
+	defer handler.lock.Unlock()
+
+	report := junitTestSuites{}
+	var totalDuration time.Duration
+
+	for _, task := range handler.tasks {
+		suite, duration := newJUnitTestSuite(task)
+		report.Suites = append(report.Suites, suite)
+		report.Tests += suite.Tests
+		report.Failures += suite.Failures
+		report.Skipped += suite.Skipped
+		totalDuration += duration
+	}
+	report.Time = formatSeconds(totalDuration)
+
+	data, err := xml.MarshalIndent(report, "", "  ")
+	if err == nil {
+		err = ioutil.WriteFile(handler.path, append([]byte(xml.Header), append(data, '\n')...), 0644)
+	}
+	if err != nil {
+		log.LogToMain(fmt.Sprintf("unable to write junit report: %v", err), log.StyleError)
+	}
+}
+
+// newJUnitTestSuite creates a testsuite for the given top-level task (with a testcase for the task command and each nested child task command), returning the suite wall time
+func newJUnitTestSuite(task *runtime.Task) (junitTestSuite, time.Duration) {
+	suite := junitTestSuite{
+		Name: task.Config.Name,
+	}
+
+	var startTime, stopTime time.Time
+	commands := make([]*runtime.Task, 0)
+	if task.Config.CmdString != "" {
+		commands = append(commands, task)
+	}
+	for _, subTask := range task.Descendants() {
+		if subTask.Config.CmdString != "" {
+			commands = append(commands, subTask)
+		}
+	}
+
+	for _, command := range commands {
+		if !command.Completed {
+			// this command was never started (the run was halted)
+			continue
+		}
+
+		testCase := newJUnitTestCase(command, suite.Name)
+		suite.Cases = append(suite.Cases, testCase)
+		suite.Tests++
+		if testCase.Failure != nil {
+			suite.Failures++
+		}
+		if testCase.Skipped != nil {
+			suite.Skipped++
+		}
+
+		if !command.Command.StartTime.IsZero() && (startTime.IsZero() || command.Command.StartTime.Before(startTime)) {
+			startTime = command.Command.StartTime
+		}
+		if command.Command.StopTime.After(stopTime) {
+			stopTime = command.Command.StopTime
+		}
+	}
+
+	var duration time.Duration
+	if !startTime.IsZero() {
+		suite.Timestamp = startTime.Format("2006-01-02T15:04:05")
+		duration = stopTime.Sub(startTime)
+	}
+	suite.Time = formatSeconds(duration)
+
+	return suite, duration
+}
+
+// newJUnitTestCase creates a testcase for the given completed task command
+func newJUnitTestCase(task *runtime.Task, className string) junitTestCase {
+	testCase := junitTestCase{
+		Name:      task.Config.Name,
+		ClassName: className,
+	}
+
+	var duration time.Duration
+	if !task.Command.StartTime.IsZero() && !task.Command.StopTime.IsZero() {
+		duration = task.Command.StopTime.Sub(task.Command.StartTime)
+	}
+	testCase.Time = formatSeconds(duration)
+
+	switch task.Status {
+	case runtime.StatusSkipped:
+		testCase.Skipped = &junitSkipped{Message: task.SkipReason}
+	case runtime.StatusUpToDate:
+		testCase.Skipped = &junitSkipped{Message: "up to date"}
+	case runtime.StatusError, runtime.StatusTimedOut:
+		message := fmt.Sprintf("exited with return code %d", task.Command.ReturnCode)
+		if task.Command.FailureReason != "" {
+			message += " (" + task.Command.FailureReason + ")"
+		}
+		testCase.Failure = &junitFailure{
+			Message:  message,
+			Type:     task.Status.String(),
+			Contents: vtclean.Clean(task.ErrorOutput(), false),
+		}
+	}
+	return testCase
+}
+
+// formatSeconds renders the given duration as fractional seconds (as expected by JUnit consumers)
+func formatSeconds(duration time.Duration) string {
+	return fmt.Sprintf("%.3f", duration.Seconds())
+}
@@ -0,0 +1,80 @@
+package handler
+
+import (
+	"github.com/wagoodman/bashful/pkg/config"
+	"github.com/wagoodman/bashful/pkg/runtime"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func Test_JUnitReport_Close(t *testing.T) {
+	startTime := time.Date(2018, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	build := newTestTask("00000000-0000-0000-0000-000000000001", config.TaskConfig{
+		Name: "build",
+		ParallelTasks: []config.TaskConfig{
+			{Name: "compile", CmdString: "make"},
+			{Name: "lint", CmdString: "lint"},
+			{Name: "package", CmdString: "make package"},
+		},
+	})
+	compile, lint := build.Children[0], build.Children[1]
+
+	compile.Completed = true
+	compile.Status = runtime.StatusSuccess
+	compile.Command.StartTime = startTime
+	compile.Command.StopTime = startTime.Add(1500 * time.Millisecond)
+
+	lint.Completed = true
+	lint.Status = runtime.StatusTimedOut
+	lint.Command.StartTime = startTime.Add(time.Second)
+	lint.Command.StopTime = startTime.Add(3 * time.Second)
+	lint.Command.ReturnCode = -1
+	lint.Command.FailureReason = "timed out after 2s"
+
+	deploy := newTestTask("00000000-0000-0000-0000-000000000002", config.TaskConfig{Name: "deploy", CmdString: "./deploy.sh"})
+	deploy.Completed = true
+	deploy.Status = runtime.StatusSkipped
+	deploy.SkipReason = "needed task 'build' did not succeed"
+
+	tempDir, err := ioutil.TempDir("", "bashful-junit")
+	if err != nil {
+		t.Fatalf("could not create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tempDir)
+	path := filepath.Join(tempDir, "report.xml")
+
+	handler := NewJUnitReport(path)
+	handler.Register(build)
+	handler.Register(deploy)
+	// a retried task is registered again, which must not duplicate its suite
+	handler.Register(build)
+	handler.Close()
+
+	expected := `<?xml version="1.0" encoding="UTF-8"?>
+<testsuites tests="3" failures="1" skipped="1" time="3.000">
+  <testsuite name="build" tests="2" failures="1" skipped="0" time="3.000" timestamp="2018-01-02T03:04:05">
+    <testcase name="compile" classname="build" time="1.500"></testcase>
+    <testcase name="lint" classname="build" time="2.000">
+      <failure message="exited with return code -1 (timed out after 2s)" type="timed-out"></failure>
+    </testcase>
+  </testsuite>
+  <testsuite name="deploy" tests="1" failures="0" skipped="1" time="0.000">
+    <testcase name="deploy" classname="deploy" time="0.000">
+      <skipped message="needed task &#39;build&#39; did not succeed"></skipped>
+    </testcase>
+  </testsuite>
+</testsuites>
+`
+
+	actual, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatalf("could not read junit report: %v", err)
+	}
+	if string(actual) != expected {
+		t.Errorf("expected junit report:\n%s\ngot:\n%s", expected, string(actual))
+	}
+}
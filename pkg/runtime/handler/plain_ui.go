@@ -0,0 +1,129 @@
+package handler
+
+import (
+	"fmt"
+	"github.com/lunixbochs/vtclean"
+	"github.com/wagoodman/bashful/pkg/config"
+	"github.com/wagoodman/bashful/pkg/runtime"
+	"io"
+	"sync"
+	"time"
+)
+
+// PlainUI writes a timestamped line for every started/finished task and every line of task output, without any
+// cursor movement or colors (suitable for CI systems or when the output is piped to a file)
+type PlainUI struct {
+	lock        sync.Mutex
+	config      *config.Config
+	writer      io.Writer
+	runtimeData *runtime.TaskStatistics
+}
+
+// NewPlainUI creates a handler that writes plain lines to the given writer
+func NewPlainUI(cfg *config.Config, writer io.Writer) *PlainUI {
+	return &PlainUI{
+		config: cfg,
+		writer: writer,
+	}
+}
+
+func (handler *PlainUI) AddRuntimeData(data *runtime.TaskStatistics) {
+	handler.runtimeData = data
+}
+
+func (handler *PlainUI) Register(task *runtime.Task) {
+
+}
+
+func (handler *PlainUI) Unregister(task *runtime.Task) {
+
+}
+
+func (handler *PlainUI) OnEvent(task *runtime.Task, e runtime.TaskEvent) {
+	handler.lock.Lock()
+	defer handler.lock.Unlock()
+
+	title := e.Task.Config.Name
+	for parent := e.Task.Parent(); parent != nil; parent = parent.Parent() {
+		title = parent.Config.Name + " > " + title
+	}
+
+	switch {
+	case e.Complete:
+		handler.println(completedMessage(e) + " " + title + completedDetails(e))
+	case e.Stdout != "" || e.Stderr != "":
+		if !e.Task.Config.ShowTaskOutput {
+			return
+		}
+		if e.Stdout != "" {
+			handler.println(title + " | " + vtclean.Clean(e.Stdout, false))
+		}
+		if e.Stderr != "" {
+			handler.println(title + " | " + vtclean.Clean(e.Stderr, false))
+		}
+	case e.Status == runtime.StatusPending && e.Task.WaitingOnPool != "":
+		handler.println("waiting " + title + " (pool '" + e.Task.WaitingOnPool + "' is full)")
+	case e.Status == runtime.StatusRunning:
+		if e.Attempt > 1 {
+			title += fmt.Sprintf(" (attempt %d/%d)", e.Attempt, e.Task.Config.Retries+1)
+		}
+		handler.println("started " + title)
+	}
+}
+
+func (handler *PlainUI) Close() {
+	handler.lock.Lock()
+	defer handler.lock.Unlock()
+
+	if !handler.config.Options.ShowSummaryFooter || handler.runtimeData == nil {
+		return
+	}
+
+	stats := handler.runtimeData
+	message := fmt.Sprintf("completed %d/%d tasks (%d failed, %d killed, %d skipped, %d up to date)",
+		len(stats.Completed), stats.Total, len(stats.Failed), len(stats.Killed), len(stats.Skipped), len(stats.UpToDate))
+	handler.println(message)
+}
+
+// println writes the given message prefixed with the current time
+func (handler *PlainUI) println(message string) {
+	fmt.Fprintf(handler.writer, "[%s] %s\n", time.Now().Format("15:04:05"), message)
+}
+
+// completedMessage describes the outcome of a completed task command in a single word
+func completedMessage(e runtime.TaskEvent) string {
+	switch e.Status {
+	case runtime.StatusSuccess:
+		return "finished"
+	case runtime.StatusSkipped:
+		return "skipped"
+	case runtime.StatusUpToDate:
+		return "up-to-date"
+	case runtime.StatusTimedOut:
+		return "timed-out"
+	default:
+		return "failed"
+	}
+}
+
+// completedDetails describes the return code, runtime, and any skip/failure reason of a completed task command
+func completedDetails(e runtime.TaskEvent) string {
+	switch e.Status {
+	case runtime.StatusSkipped:
+		if e.Task.SkipReason == "" {
+			return ""
+		}
+		return " (" + e.Task.SkipReason + ")"
+	case runtime.StatusUpToDate:
+		return ""
+	}
+
+	details := fmt.Sprintf(" (rc:%d", e.ReturnCode)
+	if !e.StartTime.IsZero() && !e.StopTime.IsZero() {
+		details += ", " + e.StopTime.Sub(e.StartTime).Round(time.Millisecond).String()
+	}
+	if e.Task.Command.FailureReason != "" {
+		details += ", " + e.Task.Command.FailureReason
+	}
+	return details + ")"
+}
@@ -0,0 +1,147 @@
+package handler
+
+import (
+	"bytes"
+	"github.com/wagoodman/bashful/pkg/config"
+	"github.com/wagoodman/bashful/pkg/runtime"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+)
+
+var plainTimePattern = regexp.MustCompile(`^\[\d\d:\d\d:\d\d\] `)
+
+func Test_PlainUI_OnEvent(t *testing.T) {
+	startTime := time.Date(2018, 1, 2, 3, 4, 5, 0, time.UTC)
+	stopTime := startTime.Add(1500 * time.Millisecond)
+
+	table := map[string]struct {
+		event    func() (*runtime.Task, runtime.TaskEvent)
+		expected []string
+	}{
+		"started task": {
+			event: func() (*runtime.Task, runtime.TaskEvent) {
+				task := newTestTask("00000000-0000-0000-0000-000000000001", config.TaskConfig{Name: "build", CmdString: "make"})
+				return task, runtime.TaskEvent{Task: task, Status: runtime.StatusRunning, ReturnCode: -1, Attempt: 1, StartTime: startTime}
+			},
+			expected: []string{"started build"},
+		},
+
+		"retried child task": {
+			event: func() (*runtime.Task, runtime.TaskEvent) {
+				task := newTestTask("00000000-0000-0000-0000-000000000002", config.TaskConfig{Name: "checks", ParallelTasks: []config.TaskConfig{{Name: "lint", CmdString: "lint <all>", Retries: 2}}})
+				child := task.Children[0]
+				return task, runtime.TaskEvent{Task: child, Status: runtime.StatusRunning, ReturnCode: -1, Attempt: 2, StartTime: startTime}
+			},
+			expected: []string{"started checks > lint (attempt 2/3)"},
+		},
+
+		"shown task output": {
+			event: func() (*runtime.Task, runtime.TaskEvent) {
+				task := newTestTask("00000000-0000-0000-0000-000000000003", config.TaskConfig{Name: "build", CmdString: "make", ShowTaskOutput: true})
+				return task, runtime.TaskEvent{Task: task, Status: runtime.StatusRunning, Stdout: "\x1b[31mcompiling\x1b[0m", Stderr: "warning", ReturnCode: -1, Attempt: 1, StartTime: startTime}
+			},
+			expected: []string{"build | compiling", "build | warning"},
+		},
+
+		"hidden task output": {
+			event: func() (*runtime.Task, runtime.TaskEvent) {
+				task := newTestTask("00000000-0000-0000-0000-000000000004", config.TaskConfig{Name: "build", CmdString: "make"})
+				return task, runtime.TaskEvent{Task: task, Status: runtime.StatusRunning, Stdout: "compiling", ReturnCode: -1, Attempt: 1, StartTime: startTime}
+			},
+			expected: nil,
+		},
+
+		"task waiting on a pool": {
+			event: func() (*runtime.Task, runtime.TaskEvent) {
+				task := newTestTask("00000000-0000-0000-0000-000000000005", config.TaskConfig{Name: "migrate", CmdString: "./migrate.sh"})
+				task.WaitingOnPool = "database"
+				return task, runtime.TaskEvent{Task: task, Status: runtime.StatusPending, ReturnCode: -1}
+			},
+			expected: []string{"waiting migrate (pool 'database' is full)"},
+		},
+
+		"finished task": {
+			event: func() (*runtime.Task, runtime.TaskEvent) {
+				task := newTestTask("00000000-0000-0000-0000-000000000006", config.TaskConfig{Name: "build", CmdString: "make"})
+				return task, runtime.TaskEvent{Task: task, Status: runtime.StatusSuccess, Complete: true, ReturnCode: 0, Attempt: 1, StartTime: startTime, StopTime: stopTime}
+			},
+			expected: []string{"finished build (rc:0, 1.5s)"},
+		},
+
+		"failed child task": {
+			event: func() (*runtime.Task, runtime.TaskEvent) {
+				task := newTestTask("00000000-0000-0000-0000-000000000007", config.TaskConfig{Name: "checks", ParallelTasks: []config.TaskConfig{{Name: "lint", CmdString: "lint <all>"}}})
+				child := task.Children[0]
+				child.Command.FailureReason = "output matched 'FAIL'"
+				return task, runtime.TaskEvent{Task: child, Status: runtime.StatusError, Stderr: "FAIL", Complete: true, ReturnCode: 2, Attempt: 1, StartTime: startTime, StopTime: stopTime}
+			},
+			expected: []string{"failed checks > lint (rc:2, 1.5s, output matched 'FAIL')"},
+		},
+
+		"skipped task": {
+			event: func() (*runtime.Task, runtime.TaskEvent) {
+				task := newTestTask("00000000-0000-0000-0000-000000000008", config.TaskConfig{Name: "deploy", CmdString: "./deploy.sh"})
+				task.SkipReason = "needed task 'build' did not succeed"
+				return task, runtime.TaskEvent{Task: task, Status: runtime.StatusSkipped, Complete: true, ReturnCode: 0}
+			},
+			expected: []string{"skipped deploy (needed task 'build' did not succeed)"},
+		},
+
+		"up to date task": {
+			event: func() (*runtime.Task, runtime.TaskEvent) {
+				task := newTestTask("00000000-0000-0000-0000-000000000009", config.TaskConfig{Name: "build", CmdString: "make"})
+				return task, runtime.TaskEvent{Task: task, Status: runtime.StatusUpToDate, Complete: true, ReturnCode: 0}
+			},
+			expected: []string{"up-to-date build"},
+		},
+	}
+
+	for name, testCase := range table {
+		t.Logf("Running test case: %s", name)
+
+		var buffer bytes.Buffer
+		handler := NewPlainUI(&config.Config{}, &buffer)
+		handler.OnEvent(testCase.event())
+		handler.Close()
+
+		var actual []string
+		if buffer.Len() > 0 {
+			actual = strings.Split(strings.TrimSuffix(buffer.String(), "\n"), "\n")
+		}
+		if len(actual) != len(testCase.expected) {
+			t.Errorf("[case: %s] expected %d lines, got %d: %q", name, len(testCase.expected), len(actual), buffer.String())
+			continue
+		}
+		for index, line := range actual {
+			if !plainTimePattern.MatchString(line) {
+				t.Errorf("[case: %s] expected a timestamp, got: %s", name, line)
+			}
+			if message := plainTimePattern.ReplaceAllString(line, ""); message != testCase.expected[index] {
+				t.Errorf("[case: %s] expected line %q, got %q", name, testCase.expected[index], message)
+			}
+		}
+	}
+}
+
+func Test_PlainUI_Close(t *testing.T) {
+	failed := newTestTask("00000000-0000-0000-0000-000000000001", config.TaskConfig{Name: "lint", CmdString: "lint"})
+	skipped := newTestTask("00000000-0000-0000-0000-000000000002", config.TaskConfig{Name: "deploy", CmdString: "./deploy.sh"})
+	succeeded := newTestTask("00000000-0000-0000-0000-000000000003", config.TaskConfig{Name: "build", CmdString: "make"})
+
+	var buffer bytes.Buffer
+	handler := NewPlainUI(&config.Config{Options: config.Options{ShowSummaryFooter: true}}, &buffer)
+	handler.AddRuntimeData(&runtime.TaskStatistics{
+		Total:     4,
+		Completed: []*runtime.Task{failed, skipped, succeeded},
+		Failed:    []*runtime.Task{failed},
+		Skipped:   []*runtime.Task{skipped},
+	})
+	handler.Close()
+
+	expected := "completed 3/4 tasks (1 failed, 0 killed, 1 skipped, 0 up to date)"
+	if actual := plainTimePattern.ReplaceAllString(strings.TrimSuffix(buffer.String(), "\n"), ""); actual != expected {
+		t.Errorf("expected summary %q, got %q", expected, actual)
+	}
+}
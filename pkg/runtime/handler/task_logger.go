@@ -30,9 +30,8 @@ type TaskLogger struct {
 }
 
 func NewTaskLogger(config *config.Config) *TaskLogger {
-	if config.Options.LogPath != "" {
-		log.SetupLogging(config.Options.LogPath, config.LogCachePath)
-	}
+	// the task logs are always written to the cache dir (even when there is no main log to concatenate them to)
+	log.SetupLogging(config.Options.LogPath, config.LogCachePath)
 
 	return &TaskLogger{
 		logs:   make(map[uuid.UUID]*bufferedLog, 0),
@@ -45,7 +44,11 @@ func (handler *TaskLogger) AddRuntimeData(data *runtime.TaskStatistics) {
 }
 
 func (handler *TaskLogger) doRegister(task *runtime.Task) {
-	tempFile, _ := ioutil.TempFile(handler.config.LogCachePath, "")
+	tempFile, err := ioutil.TempFile(handler.config.LogCachePath, "")
+	if err != nil {
+		utils.ExitWithErrorMessage("\nUnable to create task log\n" + err.Error())
+	}
+	tempFile.Close()
 
 	handler.logs[task.Id] = &bufferedLog{
 		LogFile: tempFile,
@@ -77,7 +80,13 @@ func (handler *TaskLogger) Unregister(task *runtime.Task) {
 
 	close(handler.logs[task.Id].LogChan)
 	delete(handler.logs, task.Id)
-	log.LogToMain("completed Task: "+task.Config.Name+" (rc:"+strconv.Itoa(task.Command.ReturnCode)+")", log.StyleInfo)
+	if task.Status == runtime.StatusSkipped {
+		log.LogToMain("skipped Task: "+task.Config.Name+" ("+task.SkipReason+")", log.StyleInfo)
+	} else if task.Status == runtime.StatusUpToDate {
+		log.LogToMain("up to date Task: "+task.Config.Name, log.StyleInfo)
+	} else {
+		log.LogToMain("completed Task: "+task.Config.Name+" (rc:"+strconv.Itoa(task.Command.ReturnCode)+")", log.StyleInfo)
+	}
 }
 
 func (handler *TaskLogger) OnEvent(task *runtime.Task, e runtime.TaskEvent) {
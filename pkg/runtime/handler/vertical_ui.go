@@ -11,6 +11,7 @@ import (
 	"github.com/wagoodman/bashful/utils"
 	"github.com/wagoodman/jotframe"
 	"github.com/wayneashleyberry/terminal-dimensions"
+	"golang.org/x/sys/unix"
 	"io"
 	"strconv"
 	"strings"
@@ -28,6 +29,48 @@ type VerticalUI struct {
 	startTime   time.Time
 	runtimeData *runtime.TaskStatistics
 	frame       *jotframe.FixedFrame
+
+	// activeTasks is the number of registered top-level tasks that are drawn on the current frame
+	activeTasks int
+
+	// hook is the cleanup section ('on-failure' or 'finally') of the most recently registered task
+	hook string
+
+	// interactive indicates that task lines can be selected and the full task output can be shown (see NewInteractiveUI)
+	interactive bool
+
+	// executor is where any user requested task actions are sent to (only when interactive)
+	executor *runtime.Executor
+
+	// selectable is every task line on the current frame (in display order) that can be selected
+	selectable []*display
+
+	// selected is the index of the selected task line within selectable (-1 when nothing is selected)
+	selected int
+
+	// output is the most recent stdout/stderr lines of every task (at most maxPaneLines, only collected when interactive)
+	output map[uuid.UUID][]string
+
+	// discarded is the number of older output lines of every task that are no longer kept in output
+	discarded map[uuid.UUID]int
+
+	// startTimes is when the current attempt of every started task command was started (see TaskEvent.StartTime)
+	startTimes map[uuid.UUID]time.Time
+
+	// pane is the full task output shown instead of the frame (nil when not shown)
+	pane *outputPane
+
+	// deferred is every Register/Unregister call made while the pane is shown (replayed once the pane is closed)
+	deferred []func()
+
+	// input serializes reading keys from stdin with any cursor position queries made by jotframe (which also read from stdin)
+	input sync.Mutex
+
+	// terminalState is the stdin terminal state before keys were read (nil when keys are not read)
+	terminalState *unix.Termios
+
+	// done is closed when the handler is closed (stops reading keys)
+	done chan struct{}
 }
 
 // display represents all non-Config items that control how the task line should be printed to the screen
@@ -44,6 +87,24 @@ type display struct {
 	Values lineInfo
 
 	line *jotframe.Line
+
+	// header is the line showing the title of a task with child tasks (this is the frame header unless the frame is shared with other running tasks)
+	header *jotframe.Line
+
+	// isTopLevel indicates that the task is not a child of any other task
+	isTopLevel bool
+
+	// indent is the tree lines of all parent tasks drawn before the branch of a nested child task
+	indent string
+
+	// lines is every line drawn for a top-level task and all child tasks (excluding the header)
+	lines []*jotframe.Line
+
+	// outputLines are the lines beneath the task line showing the most recent output while the task is running (see 'show-output-lines')
+	outputLines []*jotframe.Line
+
+	// recentOutput is the most recent output lines of the task (at most 'show-output-lines')
+	recentOutput []string
 }
 
 type summary struct {
@@ -71,6 +132,9 @@ type lineInfo struct {
 	// Prefix is used to place the spinner or bullet characters before the title
 	Prefix string
 
+	// Indent is used to continue the tree lines of all parent tasks before the branch of a nested child task
+	Indent string
+
 	// Eta is the displayed estimated time to completion based on the current time
 	Eta string
 
@@ -85,10 +149,10 @@ var (
 	lineDefaultTemplate, _ = template.New("default line").Parse(` {{.Status}}  ` + color.Reset + ` {{printf "%1s" .Prefix}} {{printf "%-25s" .Title}} {{.Msg}}{{.Split}}{{.Eta}}`)
 
 	// lineParallelTemplate is the string template used to display the TaskStatus values of a task that is the child of another task
-	lineParallelTemplate, _ = template.New("parallel line").Parse(` {{.Status}}  ` + color.Reset + ` {{printf "%1s" .Prefix}} ├─ {{printf "%-25s" .Title}} {{.Msg}}{{.Split}}{{.Eta}}`)
+	lineParallelTemplate, _ = template.New("parallel line").Parse(` {{.Status}}  ` + color.Reset + ` {{printf "%1s" .Prefix}} {{.Indent}}├─ {{printf "%-25s" .Title}} {{.Msg}}{{.Split}}{{.Eta}}`)
 
 	// lineLastParallelTemplate is the string template used to display the TaskStatus values of a task that is the LAST child of another task
-	lineLastParallelTemplate, _ = template.New("last parallel line").Parse(` {{.Status}}  ` + color.Reset + ` {{printf "%1s" .Prefix}} └─ {{printf "%-25s" .Title}} {{.Msg}}{{.Split}}{{.Eta}}`)
+	lineLastParallelTemplate, _ = template.New("last parallel line").Parse(` {{.Status}}  ` + color.Reset + ` {{printf "%1s" .Prefix}} {{.Indent}}└─ {{printf "%-25s" .Title}} {{.Msg}}{{.Split}}{{.Eta}}`)
 )
 
 func NewVerticalUI(cfg *config.Config) *VerticalUI {
@@ -99,11 +163,16 @@ func NewVerticalUI(cfg *config.Config) *VerticalUI {
 	}
 
 	handler := &VerticalUI{
-		data:      make(map[uuid.UUID]*display, 0),
-		spinner:   spin.New(),
-		ticker:    time.NewTicker(updateInterval),
-		startTime: time.Now(),
-		config:    cfg,
+		data:       make(map[uuid.UUID]*display, 0),
+		spinner:    spin.New(),
+		ticker:     time.NewTicker(updateInterval),
+		startTime:  time.Now(),
+		config:     cfg,
+		selected:   -1,
+		output:     make(map[uuid.UUID][]string),
+		discarded:  make(map[uuid.UUID]int),
+		startTimes: make(map[uuid.UUID]time.Time),
+		done:       make(chan struct{}),
 	}
 
 	go handler.spinnerHandler()
@@ -122,27 +191,29 @@ func (handler *VerticalUI) spinnerHandler() {
 
 		case <-handler.ticker.C:
 			handler.lock.Lock()
+			if handler.pane != nil {
+				// nothing on the frame can be drawn while the pane is shown
+				handler.lock.Unlock()
+				continue
+			}
 
 			handler.spinner.Next()
 			for _, displayData := range handler.data {
 				task := displayData.Task
-
-				if task.Config.CmdString != "" {
-					if !task.Completed && task.Started {
-						displayData.Values.Prefix = handler.spinner.Current()
-						displayData.Values.Eta = handler.CurrentEta(task)
-					}
-					handler.displayTask(task)
+				if displayData.line == nil {
+					// a top-level task without a command is only drawn as a header
+					continue
 				}
 
-				for _, subTask := range task.Children {
-					childDisplayData := handler.data[subTask.Id]
-					if !subTask.Completed && subTask.Started {
-						childDisplayData.Values.Prefix = handler.spinner.Current()
-						childDisplayData.Values.Eta = handler.CurrentEta(subTask)
-					}
-					handler.displayTask(subTask)
+				command := task
+				if len(task.Config.Steps) > 0 {
+					command, _ = currentStep(task)
+				}
+				if command.Config.CmdString != "" && !command.Completed && command.Started {
+					displayData.Values.Prefix = handler.spinner.Current()
+					displayData.Values.Eta = handler.CurrentEta(command)
 				}
+				handler.displayTask(task)
 
 				// update the summary line
 				if handler.config.Options.ShowSummaryFooter {
@@ -158,6 +229,14 @@ func (handler *VerticalUI) spinnerHandler() {
 
 // todo: move footer logic based on jotframe requirements
 func (handler *VerticalUI) Close() {
+	if handler.interactive {
+		handler.lock.Lock()
+		handler.closePane()
+		handler.selectLine(0)
+		handler.lock.Unlock()
+		handler.stopInput()
+	}
+
 	// todo: remove config references
 	if handler.config.Options.ShowSummaryFooter {
 		// todo: add footer update via Executor stats
@@ -181,40 +260,56 @@ func (handler *VerticalUI) Close() {
 }
 
 func (handler *VerticalUI) Unregister(task *runtime.Task) {
+	handler.lock.Lock()
+	defer handler.lock.Unlock()
+
+	if handler.pane != nil {
+		handler.deferred = append(handler.deferred, func() { handler.doUnregister(task) })
+		return
+	}
+	handler.doUnregister(task)
+}
+
+func (handler *VerticalUI) doUnregister(task *runtime.Task) {
 	if _, ok := handler.data[task.Id]; !ok {
 		// ignore data that have already been unregistered
 		return
 	}
-	handler.lock.Lock()
-	defer handler.lock.Unlock()
 
-	if len(task.Children) > 0 {
+	displayData := handler.data[task.Id]
+	if displayData.isTopLevel {
+		handler.activeTasks--
+	}
 
-		displayData := handler.data[task.Id]
+	if displayData.isTopLevel && len(task.Children) > 0 {
 
 		hasHeader := len(task.Children) > 0
 		collapseSection := task.Config.CollapseOnCompletion && hasHeader && task.FailedChildren == 0
 
 		// complete the proc group TaskStatus
 		if hasHeader {
-			handler.frame.Header().Open()
 			var message bytes.Buffer
 			collapseSummary := ""
 			if collapseSection {
-				collapseSummary = utils.Purple(" (" + strconv.Itoa(len(task.Children)) + " Tasks hidden)")
+				collapseSummary = utils.Purple(" (" + strconv.Itoa(len(task.Descendants())) + " Tasks hidden)")
 			}
 			displayData.Template.Execute(&message, lineInfo{Status: handler.TaskStatusColor(task.Status, "i"), Title: task.Config.Name + collapseSummary, Prefix: handler.config.Options.BulletChar})
 
-			handler.frame.Header().WriteStringAndClose(message.String())
+			if displayData.header == handler.frame.Header() {
+				displayData.header.Open()
+				displayData.header.WriteStringAndClose(message.String())
+			} else {
+				displayData.header.WriteString(message.String())
+			}
 		}
 
 		// collapse sections or parallel Tasks...
 		if collapseSection {
 			// todo: enhance jotframe to take care of this
-			length := len(handler.frame.Lines())
-			for idx := 0; idx < length; idx++ {
-				handler.frame.Remove(handler.frame.Lines()[0])
+			for _, line := range displayData.lines {
+				handler.frame.Remove(line)
 			}
+			handler.removeSelectable(displayData.lines)
 		}
 	}
 
@@ -234,15 +329,54 @@ func (handler *VerticalUI) doRegister(task *runtime.Task) {
 		numTasks++
 	}
 
-	// we should overwrite the footer of the last frame when creating a new frame (kinda hacky... todo: replace this)
-	isFirst := handler.frame == nil
-	if handler.frame != nil {
-		handler.frame.Close()
+	// cleanup tasks are drawn beneath a title for each section
+	sectionTitle := ""
+	if task.Hook != handler.hook {
+		handler.hook = task.Hook
+		switch task.Hook {
+		case runtime.HookOnFailure:
+			sectionTitle = utils.Bold(" On failure:")
+		case runtime.HookFinally:
+			sectionTitle = utils.Bold(" Finally:")
+		}
 	}
-	handler.frame = jotframe.NewFixedFrame(0, hasHeader, handler.config.Options.ShowSummaryFooter, false)
-	if !isFirst && handler.config.Options.ShowSummaryFooter {
-		handler.frame.Move(-1)
+
+	// tasks that are started while other tasks are still running (see 'needs') are drawn on the same frame
+	var header *jotframe.Line
+	if handler.activeTasks > 0 && sectionTitle == "" {
+		if hasHeader {
+			header, _ = handler.frame.Append()
+			// todo: check err
+		}
+	} else {
+		// we should overwrite the footer of the last frame when creating a new frame (kinda hacky... todo: replace this)
+		isFirst := handler.frame == nil
+		if handler.frame != nil {
+			// the lines of the previous frame can no longer be selected
+			handler.selectLine(0)
+			handler.frame.Close()
+		}
+		// creating a frame reads the cursor position from stdin
+		handler.input.Lock()
+		handler.frame = jotframe.NewFixedFrame(0, hasHeader || sectionTitle != "", handler.config.Options.ShowSummaryFooter, false)
+		handler.input.Unlock()
+		handler.selectable = nil
+		handler.selected = -1
+		if !isFirst && handler.config.Options.ShowSummaryFooter {
+			handler.frame.Move(-1)
+		}
+		header = handler.frame.Header()
+
+		if sectionTitle != "" {
+			io.WriteString(header, sectionTitle)
+			header = nil
+			if hasHeader {
+				header, _ = handler.frame.Append()
+				// todo: check err
+			}
+		}
 	}
+	handler.activeTasks++
 
 	var line *jotframe.Line
 	if hasParentCmd {
@@ -251,24 +385,18 @@ func (handler *VerticalUI) doRegister(task *runtime.Task) {
 	}
 
 	handler.data[task.Id] = &display{
-		Template: lineDefaultTemplate,
-		Index:    0,
-		Task:     task,
-		line:     line,
+		Template:   lineDefaultTemplate,
+		Index:      0,
+		Task:       task,
+		line:       line,
+		header:     header,
+		isTopLevel: true,
 	}
-	for idx, subTask := range task.Children {
-		line, _ := handler.frame.Append()
-		// todo: check err
-		handler.data[subTask.Id] = &display{
-			Template: lineParallelTemplate,
-			Index:    idx + 1,
-			Task:     subTask,
-			line:     line,
-		}
-		if idx == len(task.Children)-1 {
-			handler.data[subTask.Id].Template = lineLastParallelTemplate
-		}
+	if line != nil {
+		handler.data[task.Id].lines = append(handler.data[task.Id].lines, line)
+		handler.selectable = append(handler.selectable, handler.data[task.Id])
 	}
+	handler.registerChildren(handler.data[task.Id], task, "")
 
 	displayData := handler.data[task.Id]
 
@@ -278,7 +406,7 @@ func (handler *VerticalUI) doRegister(task *runtime.Task) {
 		lineObj := lineInfo{Status: handler.TaskStatusColor(runtime.StatusRunning, "i"), Title: task.Config.Name, Msg: "", Prefix: handler.config.Options.BulletChar}
 		displayData.Template.Execute(&message, lineObj)
 
-		io.WriteString(handler.frame.Header(), message.String())
+		io.WriteString(displayData.header, message.String())
 	}
 
 	if hasParentCmd {
@@ -286,10 +414,45 @@ func (handler *VerticalUI) doRegister(task *runtime.Task) {
 		handler.displayTask(task)
 	}
 
-	for line := 0; line < len(task.Children); line++ {
-		childDisplayData := handler.data[task.Children[line].Id]
-		childDisplayData.Values = lineInfo{Status: handler.TaskStatusColor(runtime.StatusPending, "i"), Title: task.Children[line].Config.Name}
-		handler.displayTask(task.Children[line])
+	for _, subTask := range task.Descendants() {
+		childDisplayData, ok := handler.data[subTask.Id]
+		if !ok {
+			// steps are shown on the line of the task itself
+			continue
+		}
+		childDisplayData.Values = lineInfo{Status: handler.TaskStatusColor(runtime.StatusPending, "i"), Title: subTask.Config.Name}
+		handler.displayTask(subTask)
+	}
+}
+
+// registerChildren appends a line for every (nested) child task of the given task to the frame, drawing each child task
+// as a branch of the tree beneath the parent task
+func (handler *VerticalUI) registerChildren(topDisplayData *display, task *runtime.Task, indent string) {
+	for idx, subTask := range task.Children {
+		line, _ := handler.frame.Append()
+		// todo: check err
+		topDisplayData.lines = append(topDisplayData.lines, line)
+		displayData := &display{
+			Template: lineParallelTemplate,
+			Index:    len(topDisplayData.lines),
+			Task:     subTask,
+			line:     line,
+			indent:   indent,
+		}
+		childIndent := indent + "│  "
+		if idx == len(task.Children)-1 {
+			displayData.Template = lineLastParallelTemplate
+			childIndent = indent + "   "
+		}
+		handler.data[subTask.Id] = displayData
+
+		// a nested group without a command of its own only summarizes the status of its child tasks
+		if subTask.Config.CmdString != "" || len(subTask.Config.Steps) > 0 {
+			handler.selectable = append(handler.selectable, displayData)
+		}
+		if len(subTask.Config.Steps) == 0 {
+			handler.registerChildren(topDisplayData, subTask, childIndent)
+		}
 	}
 }
 
@@ -297,6 +460,13 @@ func (handler *VerticalUI) Register(task *runtime.Task) {
 	handler.lock.Lock()
 	defer handler.lock.Unlock()
 
+	if handler.interactive {
+		handler.startInput()
+	}
+	if handler.pane != nil {
+		handler.deferred = append(handler.deferred, func() { handler.doRegister(task) })
+		return
+	}
 	handler.doRegister(task)
 
 }
@@ -306,32 +476,74 @@ func (handler *VerticalUI) OnEvent(task *runtime.Task, e runtime.TaskEvent) {
 	defer handler.lock.Unlock()
 
 	eventTask := e.Task
+	if !e.StartTime.IsZero() {
+		handler.startTimes[eventTask.Id] = e.StartTime
+	}
+
+	if handler.interactive {
+		handler.collectOutput(e)
+	}
 
 	if !eventTask.Config.ShowTaskOutput {
 		e.Stderr = ""
 		e.Stdout = ""
 	}
-	eventDisplayData := handler.data[e.Task.Id]
-
+	message := e.Stdout
 	if e.Stderr != "" {
-		eventDisplayData.Values = lineInfo{
-			Status: handler.TaskStatusColor(e.Status, "i"),
-			Title:  eventTask.Config.Name,
-			Msg:    e.Stderr,
-			Prefix: handler.spinner.Current(),
-			Eta:    handler.CurrentEta(eventTask),
+		message = e.Stderr
+	}
+	eventDisplayData, ok := handler.data[lineTask(eventTask).Id]
+	if !ok {
+		// the task is registered once the pane is closed
+		return
+	}
+	displayedTask := eventDisplayData.Task
+
+	title := eventTask.Config.Name
+	if displayedTask != eventTask {
+		// this is a step of the displayed task
+		_, number := currentStep(displayedTask)
+		title = displayedTask.Config.Name + utils.Purple(fmt.Sprintf(" (step %d/%d)", number, len(displayedTask.Children)))
+	}
+	if e.Attempt > 1 {
+		title += utils.Purple(fmt.Sprintf(" (attempt %d/%d)", e.Attempt, eventTask.Config.Retries+1))
+	}
+
+	if displayedTask.Config.ShowOutputLines > 0 {
+		// the output is shown beneath the task line instead
+		for _, line := range []string{e.Stdout, e.Stderr} {
+			if line != "" {
+				eventDisplayData.recentOutput = append(eventDisplayData.recentOutput, line)
+			}
 		}
-	} else {
-		eventDisplayData.Values = lineInfo{
-			Status: handler.TaskStatusColor(e.Status, "i"),
-			Title:  eventTask.Config.Name,
-			Msg:    e.Stdout,
-			Prefix: handler.spinner.Current(),
-			Eta:    handler.CurrentEta(eventTask),
+		if len(eventDisplayData.recentOutput) > displayedTask.Config.ShowOutputLines {
+			eventDisplayData.recentOutput = eventDisplayData.recentOutput[len(eventDisplayData.recentOutput)-displayedTask.Config.ShowOutputLines:]
 		}
+		message = ""
+	}
+
+	eventDisplayData.Values = lineInfo{
+		Status: handler.TaskStatusColor(e.Status, "i"),
+		Title:  title,
+		Msg:    message,
+		Prefix: handler.spinner.Current(),
+		Eta:    handler.CurrentEta(eventTask),
+	}
+
+	if handler.pane != nil {
+		if handler.pane.task == displayedTask {
+			handler.drawPane()
+		}
+		return
 	}
 
 	handler.displayTask(eventTask)
+	handler.displayOutputLines(eventDisplayData)
+
+	// nested groups summarize the status of all child tasks
+	for parent := eventTask.Parent(); parent != nil; parent = parent.Parent() {
+		handler.displayTask(parent)
+	}
 
 	// update the summary line
 	if handler.config.Options.ShowSummaryFooter {
@@ -343,17 +555,99 @@ func (handler *VerticalUI) OnEvent(task *runtime.Task, e runtime.TaskEvent) {
 func (handler *VerticalUI) displayTask(task *runtime.Task) {
 
 	// todo: error handling
-	if _, ok := handler.data[task.Id]; !ok {
+	displayData, ok := handler.data[lineTask(task).Id]
+	if !ok || displayData.line == nil {
+		return
+	}
+
+	handler.drawLine(displayData)
+}
+
+// displayOutputLines reserves lines beneath a running task line to show the most recent task output (see 'show-output-lines'), removing the lines once the task has completed
+func (handler *VerticalUI) displayOutputLines(displayData *display) {
+	task := displayData.Task
+	if task.Config.ShowOutputLines <= 0 {
 		return
 	}
 
+	started, completed := task.Started, task.Completed
+	if len(task.Config.Steps) > 0 {
+		status := groupStatus(task)
+		started = status != runtime.StatusPending
+		completed = started && status != runtime.StatusRunning
+	}
+
+	if completed || !started {
+		for _, line := range displayData.outputLines {
+			handler.frame.Remove(line)
+		}
+		displayData.outputLines = nil
+		return
+	}
+
+	if len(displayData.outputLines) == 0 {
+		index := -1
+		for idx, line := range handler.frame.Lines() {
+			if line == displayData.line {
+				index = idx
+			}
+		}
+		if index < 0 {
+			return
+		}
+		for idx := 1; idx <= task.Config.ShowOutputLines; idx++ {
+			line, err := handler.frame.Insert(index + idx)
+			if err != nil {
+				break
+			}
+			displayData.outputLines = append(displayData.outputLines, line)
+		}
+	}
+
 	terminalWidth, _ := terminaldimensions.Width()
+	for idx, message := range handler.renderOutputLines(displayData, int(terminalWidth)) {
+		io.WriteString(displayData.outputLines[idx], message)
+	}
+}
 
-	displayData := handler.data[task.Id]
+// renderOutputLines renders every reserved output line of a running task (blank lines are rendered until the task has
+// written enough output to fill all lines)
+func (handler *VerticalUI) renderOutputLines(displayData *display, terminalWidth int) []string {
+	// the output is indented beneath the task title (continuing the parallel task tree)
+	indent := " " + handler.TaskStatusColor(runtime.StatusRunning, "i") + "  " + color.Reset + "   " + displayData.indent
+	switch displayData.Template {
+	case lineParallelTemplate:
+		indent += "│    "
+	case lineLastParallelTemplate:
+		indent += "     "
+	default:
+		indent += "  "
+	}
+	maxWidth := terminalWidth - utils.VisualLength(indent)
+
+	rendered := make([]string, len(displayData.outputLines))
+	for idx := range displayData.outputLines {
+		message := ""
+		if idx < len(displayData.recentOutput) {
+			message = displayData.recentOutput[idx]
+			if utils.VisualLength(message) > maxWidth {
+				message = utils.TrimToVisualLength(message, maxWidth-3) + "..."
+			}
+		}
+		rendered[idx] = indent + message + color.Reset
+	}
+	return rendered
+}
 
-	renderedLine := handler.renderTask(task, int(terminalWidth))
-	io.WriteString(displayData.line, renderedLine)
+// drawLine renders the given task line to the screen (marking the line when selected)
+func (handler *VerticalUI) drawLine(displayData *display) {
+	terminalWidth, _ := terminaldimensions.Width()
 
+	renderedLine := handler.renderTask(displayData, int(terminalWidth))
+	if handler.selected >= 0 && handler.selected < len(handler.selectable) && handler.selectable[handler.selected] == displayData {
+		renderedLine = utils.Bold(">") + strings.TrimPrefix(renderedLine, " ")
+	}
+	io.WriteString(displayData.line, renderedLine)
 }
 
 func (handler *VerticalUI) footer(status runtime.TaskStatus, message string) string {
@@ -375,6 +669,15 @@ func (handler *VerticalUI) footer(status runtime.TaskStatus, message string) str
 
 	if handler.config.Options.ShowSummarySteps {
 		stepString = fmt.Sprintf(" Tasks[%d/%d]", len(handler.runtimeData.Completed), handler.runtimeData.Total)
+		if len(handler.runtimeData.Skipped) > 0 {
+			stepString += fmt.Sprintf(" Skipped[%d]", len(handler.runtimeData.Skipped))
+		}
+		if len(handler.runtimeData.UpToDate) > 0 {
+			stepString += fmt.Sprintf(" UpToDate[%d]", len(handler.runtimeData.UpToDate))
+		}
+		if len(handler.runtimeData.Killed) > 0 {
+			stepString += fmt.Sprintf(" Killed[%d]", len(handler.runtimeData.Killed))
+		}
 	}
 
 	if handler.config.Options.ShowSummaryErrors {
@@ -402,15 +705,41 @@ func (handler *VerticalUI) footer(status runtime.TaskStatus, message string) str
 }
 
 // String represents the task status and command output in a single line
-func (handler *VerticalUI) renderTask(task *runtime.Task, terminalWidth int) string {
-	displayData := handler.data[task.Id]
+func (handler *VerticalUI) renderTask(displayData *display, terminalWidth int) string {
+	task := displayData.Task
 
-	if task.Completed {
+	// a task with steps is shown as the current step
+	command := task
+	if len(task.Config.Steps) > 0 {
+		command, _ = currentStep(task)
+	}
+
+	if command.Completed {
 		displayData.Values.Eta = ""
-		if task.Command.ReturnCode != 0 && !task.Config.IgnoreFailure {
-			displayData.Values.Msg = utils.Red("Exited with error (" + strconv.Itoa(task.Command.ReturnCode) + ")")
+		if command.Status == runtime.StatusSkipped {
+			displayData.Values.Status = handler.TaskStatusColor(runtime.StatusSkipped, "i")
+			displayData.Values.Msg = utils.Purple("Skipped (" + command.SkipReason + ")")
+		} else if command.Status == runtime.StatusUpToDate {
+			displayData.Values.Status = handler.TaskStatusColor(runtime.StatusUpToDate, "i")
+			displayData.Values.Msg = utils.Purple("Up to date")
+		} else if command.Killed() && !command.Config.IgnoreFailure {
+			displayData.Values.Msg = utils.Red("Killed (" + command.Command.FailureReason + ")")
+		} else if command.Command.TimedOut && !command.Config.IgnoreFailure {
+			displayData.Values.Msg = utils.Red("Terminated, " + command.Command.FailureReason)
+		} else if command.Command.FailureReason != "" && !command.Config.IgnoreFailure {
+			displayData.Values.Msg = utils.Red("Failed (" + command.Command.FailureReason + ")")
+		} else if command.Command.ReturnCode != 0 && len(command.Config.SuccessCodes) == 0 && !command.Config.IgnoreFailure {
+			displayData.Values.Msg = utils.Red("Exited with error (" + strconv.Itoa(command.Command.ReturnCode) + ")")
 		}
+	} else if !command.Started && command.WaitingOnPool != "" {
+		displayData.Values.Msg = utils.Purple("Waiting on pool '" + command.WaitingOnPool + "'")
+	}
+
+	// a nested group without a command of its own shows the status of all child tasks
+	if task.Parent() != nil && task.Config.CmdString == "" && len(task.Children) > 0 {
+		displayData.Values.Status = handler.TaskStatusColor(groupStatus(task), "i")
 	}
+	displayData.Values.Indent = displayData.indent
 
 	// set the name
 	if task.Config.Name == "" {
@@ -450,7 +779,7 @@ func (handler *VerticalUI) renderTask(task *runtime.Task, terminalWidth int) str
 	// override the current spinner to empty or a handler.config.Options.BulletChar
 	if (!task.Started || task.Completed) && len(task.Children) == 0 && displayData.Template == lineDefaultTemplate {
 		displayData.Values.Prefix = handler.config.Options.BulletChar
-	} else if task.Completed {
+	} else if command.Completed {
 		displayData.Values.Prefix = ""
 	}
 
@@ -460,6 +789,50 @@ func (handler *VerticalUI) renderTask(task *runtime.Task, terminalWidth int) str
 	return message.String()
 }
 
+// lineTask returns the task whose line shows the given task (the steps of a task are shown on the line of the task itself)
+func lineTask(task *runtime.Task) *runtime.Task {
+	if parent := task.Parent(); parent != nil && len(parent.Config.Steps) > 0 {
+		return parent
+	}
+	return task
+}
+
+// currentStep returns the first failed step of the given task (see 'steps'), otherwise the step that is running (or has been run last), along with the step number
+func currentStep(task *runtime.Task) (*runtime.Task, int) {
+	index := 0
+	for idx, step := range task.Children {
+		if step.Completed && (step.Status == runtime.StatusError || step.Status == runtime.StatusTimedOut) {
+			return step, idx + 1
+		}
+		if step.Started {
+			index = idx
+		}
+	}
+	return task.Children[index], index + 1
+}
+
+// groupStatus summarizes the status of all (nested) child task commands of the given task
+func groupStatus(task *runtime.Task) runtime.TaskStatus {
+	var started, remaining bool
+	for _, subTask := range task.Descendants() {
+		if subTask.Config.CmdString == "" {
+			continue
+		}
+		started = started || subTask.Started
+		remaining = remaining || !subTask.Completed
+	}
+
+	switch {
+	case !started:
+		return runtime.StatusPending
+	case remaining:
+		return runtime.StatusRunning
+	case task.FailedChildren > 0:
+		return runtime.StatusError
+	}
+	return task.Status
+}
+
 // TaskStatusColor returns the ansi color value represented by the given TaskStatus
 func (handler *VerticalUI) TaskStatusColor(status runtime.TaskStatus, attributes string) string {
 	switch status {
@@ -469,12 +842,15 @@ func (handler *VerticalUI) TaskStatusColor(status runtime.TaskStatus, attributes
 	case runtime.StatusPending:
 		return color.ColorCode(strconv.Itoa(handler.config.Options.ColorPending) + "+" + attributes)
 
-	case runtime.StatusSuccess:
+	case runtime.StatusSuccess, runtime.StatusUpToDate:
 		return color.ColorCode(strconv.Itoa(handler.config.Options.ColorSuccess) + "+" + attributes)
 
-	case runtime.StatusError:
+	case runtime.StatusError, runtime.StatusTimedOut:
 		return color.ColorCode(strconv.Itoa(handler.config.Options.ColorError) + "+" + attributes)
 
+	case runtime.StatusSkipped:
+		return color.ColorCode(strconv.Itoa(handler.config.Options.ColorSkipped) + "+" + attributes)
+
 	}
 	return "INVALID COMMAND STATUS"
 }
@@ -484,7 +860,7 @@ func (handler *VerticalUI) CurrentEta(task *runtime.Task) string {
 	var eta, etaValue string
 
 	if task.Options.ShowTaskEta {
-		running := time.Since(task.Command.StartTime)
+		running := time.Since(handler.startTimes[task.Id])
 		etaValue = "Unknown!"
 		if task.Command.EstimatedRuntime > 0 {
 			etaValue = utils.FormatDuration(time.Duration(task.Command.EstimatedRuntime.Seconds()-running.Seconds()) * time.Second)
@@ -0,0 +1,95 @@
+package handler
+
+import (
+	"github.com/lunixbochs/vtclean"
+	"github.com/wagoodman/bashful/pkg/config"
+	"github.com/wagoodman/bashful/pkg/runtime"
+	"github.com/wagoodman/jotframe"
+	"strings"
+	"testing"
+)
+
+func Test_VerticalUI_renderOutputLines(t *testing.T) {
+	table := map[string]struct {
+		displayData *display
+		width       int
+		expected    []string
+	}{
+		"top-level task with fewer output lines than reserved": {
+			displayData: &display{Template: lineDefaultTemplate, outputLines: make([]*jotframe.Line, 3), recentOutput: []string{"compiling", "linking"}},
+			width:       80,
+			expected:    []string{"        compiling", "        linking", "        "},
+		},
+		"parallel task": {
+			displayData: &display{Template: lineParallelTemplate, indent: "│  ", outputLines: make([]*jotframe.Line, 2), recentOutput: []string{"compiling", "linking"}},
+			width:       80,
+			expected:    []string{"      │  │    compiling", "      │  │    linking"},
+		},
+		"last parallel task": {
+			displayData: &display{Template: lineLastParallelTemplate, outputLines: make([]*jotframe.Line, 1), recentOutput: []string{"compiling"}},
+			width:       80,
+			expected:    []string{"           compiling"},
+		},
+		"output wider than the terminal": {
+			displayData: &display{Template: lineDefaultTemplate, outputLines: make([]*jotframe.Line, 1), recentOutput: []string{strings.Repeat("x", 30)}},
+			width:       20,
+			expected:    []string{"        " + strings.Repeat("x", 9) + "..."},
+		},
+	}
+
+	for name, testCase := range table {
+		t.Logf("Running test case: %s", name)
+
+		handler := NewVerticalUI(&config.Config{})
+		handler.ticker.Stop()
+
+		rendered := handler.renderOutputLines(testCase.displayData, testCase.width)
+		if len(rendered) != len(testCase.expected) {
+			t.Errorf("[case: %s] expected %d lines, got %d: %q", name, len(testCase.expected), len(rendered), rendered)
+			continue
+		}
+		for index, line := range rendered {
+			if actual := vtclean.Clean(line, false); actual != testCase.expected[index] {
+				t.Errorf("[case: %s] expected line %q, got %q", name, testCase.expected[index], actual)
+			}
+		}
+	}
+}
+
+func Test_VerticalUI_displayOutputLines(t *testing.T) {
+	handler := NewVerticalUI(&config.Config{})
+	defer handler.ticker.Stop()
+
+	task := newTestTask("00000000-0000-0000-0000-000000000001", config.TaskConfig{Name: "build", CmdString: "make", ShowTaskOutput: true, ShowOutputLines: 2})
+	handler.frame = jotframe.NewFixedFrameAt(0, false, false, false, 1)
+	line, _ := handler.frame.Append()
+	next, _ := handler.frame.Append()
+	displayData := &display{Template: lineDefaultTemplate, Task: task, line: line, lines: []*jotframe.Line{line}}
+	handler.data[task.Id] = displayData
+
+	// the output lines are reserved beneath the task line once the task is running
+	task.Started = true
+	for _, output := range []string{"compiling", "linking", "packaging"} {
+		handler.OnEvent(task, runtime.TaskEvent{Task: task, Status: runtime.StatusRunning, Stdout: output, ReturnCode: -1, Attempt: 1})
+	}
+
+	lines := handler.frame.Lines()
+	if len(displayData.outputLines) != 2 || len(lines) != 4 {
+		t.Fatalf("expected 2 reserved output lines (4 frame lines), got %d (%d frame lines)", len(displayData.outputLines), len(lines))
+	}
+	if lines[0] != line || lines[1] != displayData.outputLines[0] || lines[2] != displayData.outputLines[1] || lines[3] != next {
+		t.Errorf("expected the output lines to be reserved directly beneath the task line")
+	}
+	if strings.Join(displayData.recentOutput, ",") != "linking,packaging" {
+		t.Errorf("expected the 2 most recent output lines, got %q", displayData.recentOutput)
+	}
+
+	// the output lines are removed once the task has completed
+	task.Completed = true
+	task.Status = runtime.StatusSuccess
+	handler.OnEvent(task, runtime.TaskEvent{Task: task, Status: runtime.StatusSuccess, Complete: true, ReturnCode: 0, Attempt: 1})
+
+	if len(displayData.outputLines) != 0 || len(handler.frame.Lines()) != 2 {
+		t.Errorf("expected the output lines to be removed, got %d (%d frame lines)", len(displayData.outputLines), len(handler.frame.Lines()))
+	}
+}
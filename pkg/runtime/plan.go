@@ -0,0 +1,279 @@
+// Copyright © 2018 Alex Goodman
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package runtime
+
+import (
+	"bytes"
+	"encoding/json"
+	"github.com/wagoodman/bashful/utils"
+	"io"
+	"strings"
+	"time"
+)
+
+// Plan describes everything that would be run for the given user yaml (after all for-each, argument, $include, and tag processing)
+type Plan struct {
+	// Tasks is every top-level task that would be run
+	Tasks []PlanTask `json:"tasks"`
+
+	// OnFailure is every task that would be run after all other tasks only when a task has failed
+	OnFailure []PlanTask `json:"on-failure,omitempty"`
+
+	// Finally is every task that would always be run after all other tasks
+	Finally []PlanTask `json:"finally,omitempty"`
+
+	// Downloads is every url that would be downloaded before any task is run
+	Downloads []string `json:"downloads"`
+
+	// RequiresSudo indicates that the sudo password would be requested before any task is run
+	RequiresSudo bool `json:"requires-sudo"`
+
+	// EstimatedSeconds is the expected runtime of all tasks (based off of cached values from previous runs)
+	EstimatedSeconds float64 `json:"eta-seconds"`
+}
+
+// PlanTask describes a single task that would be run
+type PlanTask struct {
+	Name    string   `json:"name"`
+	Id      string   `json:"id,omitempty"`
+	Cmd     string   `json:"cmd,omitempty"`
+	Cwd     string   `json:"cwd,omitempty"`
+	URL     string   `json:"url,omitempty"`
+	Sudo    bool     `json:"sudo,omitempty"`
+	Tags    []string `json:"tags,omitempty"`
+	Needs   []string `json:"needs,omitempty"`
+	Pool    string   `json:"pool,omitempty"`
+	When    string   `json:"when,omitempty"`
+	Unless  string   `json:"unless,omitempty"`
+	Inputs  []string `json:"inputs,omitempty"`
+	Outputs []string `json:"outputs,omitempty"`
+
+	// ForEachCmd is the command whose output lines the task would be duplicated for (the command is not run for the plan)
+	ForEachCmd string `json:"for-each-cmd,omitempty"`
+
+	// OnFailure is every command that would be run after all other tasks when this task has failed
+	OnFailure []string `json:"on-failure,omitempty"`
+
+	// EstimatedSeconds is the expected runtime of the task (nil when the task has not been run before)
+	EstimatedSeconds *float64 `json:"eta-seconds,omitempty"`
+
+	// Tasks is every child task that would be run one after another
+	Tasks []PlanTask `json:"tasks,omitempty"`
+
+	// Steps is every command that would be run one after another as part of the task
+	Steps []PlanTask `json:"steps,omitempty"`
+
+	// ParallelTasks is every child task that would be run concurrently
+	ParallelTasks []PlanTask `json:"parallel-tasks,omitempty"`
+}
+
+func newPlanTask(task *Task) PlanTask {
+	plan := PlanTask{
+		Name:       task.Config.Name,
+		Id:         task.Config.Id,
+		Cmd:        task.Config.CmdString,
+		Cwd:        task.Config.CwdString,
+		URL:        task.Config.URL,
+		Sudo:       task.Config.Sudo,
+		Tags:       task.Config.Tags,
+		Needs:      task.Config.Needs,
+		Pool:       task.Config.Pool,
+		ForEachCmd: task.Config.ForEachCmd,
+		When:       task.Config.When,
+		Unless:     task.Config.Unless,
+		Inputs:     task.Config.Inputs,
+		Outputs:    task.Config.Outputs,
+		OnFailure:  task.Config.OnFailure,
+	}
+
+	if task.Config.CmdString != "" || task.Config.URL != "" {
+		if task.Command.EstimatedRuntime != -1 {
+			seconds := task.Command.EstimatedRuntime.Seconds()
+			plan.EstimatedSeconds = &seconds
+		}
+	} else {
+		seconds := task.estimateRuntime()
+		plan.EstimatedSeconds = &seconds
+	}
+
+	for _, subTask := range task.Children {
+		if len(task.Config.Steps) > 0 {
+			plan.Steps = append(plan.Steps, newPlanTask(subTask))
+		} else if task.sequential {
+			plan.Tasks = append(plan.Tasks, newPlanTask(subTask))
+		} else {
+			plan.ParallelTasks = append(plan.ParallelTasks, newPlanTask(subTask))
+		}
+	}
+	return plan
+}
+
+// Plan assembles everything that would be run, without running any task or downloading any url
+func (client *Client) Plan() *Plan {
+	// note: this only reads the ETA cache (no other cached state is modified)
+	client.Executor.estimateRuntime()
+
+	plan := &Plan{
+		Tasks:            make([]PlanTask, 0),
+		Downloads:        make([]string, 0),
+		EstimatedSeconds: client.Config.TotalEtaSeconds,
+	}
+
+	downloads := make(map[string]bool)
+	for _, task := range client.Executor.allTasks() {
+		if task.requiresSudoPassword() {
+			plan.RequiresSudo = true
+		}
+		for _, leaf := range append([]*Task{task}, task.Descendants()...) {
+			if leaf.Config.URL != "" && !downloads[leaf.Config.URL] {
+				downloads[leaf.Config.URL] = true
+				plan.Downloads = append(plan.Downloads, leaf.Config.URL)
+			}
+		}
+	}
+
+	for _, task := range client.Executor.Tasks {
+		plan.Tasks = append(plan.Tasks, newPlanTask(task))
+	}
+	for _, task := range client.Executor.onFailureTasks {
+		plan.OnFailure = append(plan.OnFailure, newPlanTask(task))
+	}
+	for _, task := range client.Executor.finallyTasks {
+		plan.Finally = append(plan.Finally, newPlanTask(task))
+	}
+	return plan
+}
+
+// DryRun writes the execution plan to the given writer (as a tree or as json) without running any task
+func (client *Client) DryRun(writer io.Writer, asJson bool) error {
+	plan := client.Plan()
+
+	if asJson {
+		encoder := json.NewEncoder(writer)
+		encoder.SetEscapeHTML(false)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(plan)
+	}
+
+	_, err := io.WriteString(writer, plan.String())
+	return err
+}
+
+// String renders the plan as a human readable tree
+func (plan *Plan) String() string {
+	var buffer bytes.Buffer
+
+	sections := []struct {
+		title string
+		tasks []PlanTask
+	}{
+		{"Tasks", plan.Tasks},
+		{"On failure", plan.OnFailure},
+		{"Finally", plan.Finally},
+	}
+	for _, section := range sections {
+		if len(section.tasks) == 0 {
+			continue
+		}
+		buffer.WriteString(utils.Bold(section.title+":") + "\n")
+		for _, task := range section.tasks {
+			task.write(&buffer, "  ")
+		}
+		buffer.WriteString("\n")
+	}
+
+	if len(plan.Downloads) > 0 {
+		buffer.WriteString(utils.Bold("Downloads:") + "\n")
+		for _, url := range plan.Downloads {
+			buffer.WriteString("  • " + url + "\n")
+		}
+		buffer.WriteString("\n")
+	}
+
+	if plan.RequiresSudo {
+		buffer.WriteString(utils.Bold("Requires sudo: ") + "yes\n")
+	}
+	buffer.WriteString(utils.Bold("Estimated runtime: ") + utils.FormatDuration(time.Duration(plan.EstimatedSeconds*float64(time.Second))) + "\n")
+
+	return buffer.String()
+}
+
+// write renders the task (and all nested child tasks) as a tree with the given indentation
+func (task *PlanTask) write(buffer *bytes.Buffer, indent string) {
+	buffer.WriteString(indent + "• " + utils.Bold(task.Name) + "\n")
+
+	var details [][2]string
+	add := func(key, value string) {
+		if value != "" {
+			details = append(details, [2]string{key, value})
+		}
+	}
+	add("id", task.Id)
+	add("cmd", task.Cmd)
+	add("cwd", task.Cwd)
+	add("url", task.URL)
+	if task.Sudo {
+		add("sudo", "yes")
+	}
+	add("tags", strings.Join(task.Tags, ", "))
+	add("needs", strings.Join(task.Needs, ", "))
+	add("pool", task.Pool)
+	add("for-each-cmd", task.ForEachCmd)
+	add("when", task.When)
+	add("unless", task.Unless)
+	add("inputs", strings.Join(task.Inputs, ", "))
+	add("outputs", strings.Join(task.Outputs, ", "))
+	add("on-failure", strings.Join(task.OnFailure, "; "))
+	if task.EstimatedSeconds != nil {
+		add("eta", utils.FormatDuration(time.Duration(*task.EstimatedSeconds*float64(time.Second))))
+	} else {
+		add("eta", "unknown")
+	}
+
+	for idx, detail := range details {
+		branch := "├─ "
+		if idx == len(details)-1 && len(task.ParallelTasks) == 0 && len(task.Tasks) == 0 && len(task.Steps) == 0 {
+			branch = "└─ "
+		}
+		buffer.WriteString(indent + "  " + branch + utils.Blue(detail[0]+": ") + detail[1] + "\n")
+	}
+
+	if len(task.Steps) > 0 {
+		buffer.WriteString(indent + "  └─ " + utils.Blue("steps:") + "\n")
+		for _, step := range task.Steps {
+			step.write(buffer, indent+"     ")
+		}
+	}
+
+	if len(task.Tasks) > 0 {
+		buffer.WriteString(indent + "  └─ " + utils.Blue("tasks:") + "\n")
+		for _, subTask := range task.Tasks {
+			subTask.write(buffer, indent+"     ")
+		}
+	}
+
+	if len(task.ParallelTasks) > 0 {
+		buffer.WriteString(indent + "  └─ " + utils.Blue("parallel-tasks:") + "\n")
+		for _, subTask := range task.ParallelTasks {
+			subTask.write(buffer, indent+"     ")
+		}
+	}
+}
@@ -0,0 +1,132 @@
+package runtime
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"github.com/deckarep/golang-set"
+	"github.com/wagoodman/bashful/pkg/config"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func Test_Client_Plan(t *testing.T) {
+	runYaml := []byte(`
+tasks:
+  - name: Building $1
+    cmd: make $1
+    sudo: true
+  - name: Testing
+    tags: slow
+    cmd: make test
+  - name: Deploying
+    parallel-tasks:
+      - name: Deploying <replace>
+        url: https://example.com/deploy.sh
+        cmd: <exec> <replace>
+        for-each: [app-1, app-2]
+finally:
+  - cmd: make clean
+`)
+
+	tempDir, err := ioutil.TempDir("", "bashful-plan")
+	if err != nil {
+		t.Fatalf("unable to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	cli := config.Cli{Args: []string{"all"}, RunTags: []string{"fast"}, RunTagSet: mapset.NewSet()}
+	cli.RunTagSet.Add("fast")
+	cfg, err := config.NewConfig(runYaml, &cli)
+	if err != nil {
+		t.Fatalf("config creation failed: %v", err)
+	}
+	cfg.CachePath = tempDir
+	cfg.EtaCachePath = filepath.Join(tempDir, "eta")
+
+	client, err := NewClientFromConfig(cfg)
+	if err != nil {
+		t.Fatalf("client creation failed: %v", err)
+	}
+
+	plan := client.Plan()
+
+	if len(plan.Tasks) != 2 {
+		t.Fatalf("expected 2 tasks, got %d", len(plan.Tasks))
+	}
+	if plan.Tasks[0].Name != "Building all" || plan.Tasks[0].Cmd != "make all" {
+		t.Errorf("expected argument substitution, got name='%s' cmd='%s'", plan.Tasks[0].Name, plan.Tasks[0].Cmd)
+	}
+	if len(plan.Tasks[1].ParallelTasks) != 2 || plan.Tasks[1].ParallelTasks[1].Name != "Deploying app-2" {
+		t.Errorf("expected for-each expansion, got %+v", plan.Tasks[1].ParallelTasks)
+	}
+	if len(plan.Finally) != 1 || plan.Finally[0].Cmd != "make clean" {
+		t.Errorf("expected a finally task, got %+v", plan.Finally)
+	}
+	if len(plan.Downloads) != 1 || plan.Downloads[0] != "https://example.com/deploy.sh" {
+		t.Errorf("expected a single download, got %+v", plan.Downloads)
+	}
+	if !plan.RequiresSudo {
+		t.Errorf("expected sudo to be required")
+	}
+
+	for _, task := range client.Executor.Tasks {
+		if task.Started {
+			t.Errorf("expected task '%s' to not be started", task.Config.Name)
+		}
+	}
+
+	var buffer bytes.Buffer
+	err = client.DryRun(&buffer, true)
+	if err != nil {
+		t.Fatalf("dry run failed: %v", err)
+	}
+	var decoded Plan
+	err = json.Unmarshal(buffer.Bytes(), &decoded)
+	if err != nil {
+		t.Fatalf("unable to decode json plan: %v", err)
+	}
+	if len(decoded.Tasks) != 2 {
+		t.Errorf("expected 2 tasks in the json plan, got %d", len(decoded.Tasks))
+	}
+}
+
+func Test_Client_Plan_SideEffectFree(t *testing.T) {
+	tempDir, err := ioutil.TempDir("", "bashful-plan")
+	if err != nil {
+		t.Fatalf("unable to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	runYaml := []byte(fmt.Sprintf(`
+tasks:
+  - name: Deploying <replace>
+    cmd: ./deploy.sh <replace>
+    for-each-cmd: touch %s && echo app-1
+`, filepath.Join(tempDir, "listed")))
+
+	cfg, err := config.NewConfig(runYaml, &config.Cli{DryRun: true})
+	if err != nil {
+		t.Fatalf("config creation failed: %v", err)
+	}
+	cfg.CachePath = filepath.Join(tempDir, "cache")
+	cfg.EtaCachePath = filepath.Join(cfg.CachePath, "eta")
+
+	client, err := NewClientFromConfig(cfg)
+	if err != nil {
+		t.Fatalf("client creation failed: %v", err)
+	}
+
+	plan := client.Plan()
+
+	if len(plan.Tasks) != 1 || plan.Tasks[0].Name != "Deploying <replace>" || plan.Tasks[0].ForEachCmd == "" {
+		t.Errorf("expected a single unexpanded task, got %+v", plan.Tasks)
+	}
+	for _, path := range []string{filepath.Join(tempDir, "listed"), cfg.CachePath} {
+		if _, err := os.Stat(path); !os.IsNotExist(err) {
+			t.Errorf("expected '%s' to not be created by the plan", path)
+		}
+	}
+}
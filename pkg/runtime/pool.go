@@ -0,0 +1,67 @@
+// Copyright © 2018 Alex Goodman
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package runtime
+
+import (
+	"github.com/wagoodman/bashful/pkg/log"
+)
+
+// pool returns the name of the resource pool the Task command is run in (inherited from the nearest parent Task with a pool)
+func (task *Task) pool() string {
+	for current := task; current != nil; current = current.parent {
+		if current.Config.Pool != "" {
+			return current.Config.Pool
+		}
+	}
+	return ""
+}
+
+// acquirePool reserves a slot in the resource pool of the given Task command, returning false when the pool is full (in
+// which case the Task is noted as waiting on the pool). Tasks that will not be run never wait on a pool.
+func (executor *Executor) acquirePool(task *Task) bool {
+	name := task.pool()
+	if name == "" || task.SkipReason != "" || executor.interrupted {
+		return true
+	}
+
+	if executor.poolUsage[name] >= executor.config.Options.Pools[name] {
+		if task.WaitingOnPool == "" {
+			task.WaitingOnPool = name
+			log.LogToMain("task '"+task.Config.Name+"' is waiting on pool '"+name+"'", log.StyleInfo)
+			executor.onEvent(TaskEvent{Task: task, Status: StatusPending, ReturnCode: -1})
+		}
+		return false
+	}
+
+	executor.poolUsage[name]++
+	task.heldPool = name
+	task.WaitingOnPool = ""
+	return true
+}
+
+// releasePool frees the resource pool slot held by the given completed Task command (if any)
+func (executor *Executor) releasePool(task *Task) {
+	if task.heldPool == "" {
+		return
+	}
+	executor.poolUsage[task.heldPool]--
+	task.heldPool = ""
+}
@@ -24,15 +24,32 @@ import (
 	"github.com/wagoodman/bashful/utils"
 	"os"
 	"os/signal"
+	"sync/atomic"
 	"syscall"
 )
 
+var (
+	// interrupted is closed upon the first keyboard interrupt while Tasks are being run, allowing the Executor to stop
+	// all running Tasks and to run any cleanup Tasks before exiting
+	interrupted = make(chan struct{})
+
+	// executing indicates that an Executor is running Tasks (and will handle the first keyboard interrupt)
+	executing int32
+)
+
 func Setup() {
 	sigChannel := make(chan os.Signal, 2)
 	signal.Notify(sigChannel, syscall.SIGINT, syscall.SIGTERM)
 	go func() {
+		interruptCount := 0
 		for sig := range sigChannel {
 			if sig == syscall.SIGINT {
+				interruptCount++
+				// a second interrupt (or any interrupt outside of task execution) exits immediately
+				if interruptCount == 1 && atomic.LoadInt32(&executing) == 1 {
+					close(interrupted)
+					continue
+				}
 				utils.ExitWithErrorMessage(utils.Red("Keyboard Interrupt"))
 			} else if sig == syscall.SIGTERM {
 				utils.Exit(0)
@@ -0,0 +1,160 @@
+// Copyright © 2018 Alex Goodman
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package runtime
+
+import (
+	"fmt"
+	"github.com/wagoodman/bashful/pkg/log"
+	"github.com/wagoodman/bashful/utils"
+	"os"
+	"strings"
+)
+
+// resumedReason is the skip reason shown for tasks that are not run again when resuming a run
+const resumedReason = "succeeded in a previous run"
+
+// runState is the persisted outcome of the last run, allowing a failed run to be resumed from the point of failure
+type runState struct {
+	// YamlPath is the path to the user yaml that was run
+	YamlPath string
+
+	// Tasks is the last known status of every task command (keyed by the task stateKey)
+	Tasks map[string]TaskStatus
+
+	// Environment is a mapping of all environment variables captured from the completed tasks
+	Environment map[string]string
+
+	// Exports is the set of env vars exported by every completed task command (keyed by the task stateKey), which are
+	// recorded along with the task status since the Environment is only updated once the top-level task has finished
+	Exports map[string]map[string]string
+}
+
+func newRunState(yamlPath string) *runState {
+	return &runState{
+		YamlPath:    yamlPath,
+		Tasks:       make(map[string]TaskStatus),
+		Environment: make(map[string]string),
+		Exports:     make(map[string]map[string]string),
+	}
+}
+
+// assignStateKeys gives every task command a key that identifies the same command across runs
+func (executor *Executor) assignStateKeys() {
+	occurrences := make(map[string]int)
+	// the prefix is the name of every parent task (nested child tasks are named 'parent > child' and so on)
+	var assign func(task *Task, prefix string)
+	assign = func(task *Task, prefix string) {
+		if task.Config.CmdString != "" {
+			key := strings.Join([]string{prefix, task.Config.Name, task.Config.CmdString, task.Config.CwdString}, " | ")
+			occurrences[key]++
+			if occurrences[key] > 1 {
+				key = fmt.Sprintf("%s #%d", key, occurrences[key])
+			}
+			task.stateKey = key
+		}
+
+		childPrefix := task.Config.Name
+		if prefix != "" {
+			childPrefix = prefix + " > " + task.Config.Name
+		}
+		for _, subTask := range task.Children {
+			assign(subTask, childPrefix)
+		}
+	}
+
+	for _, task := range executor.Tasks {
+		assign(task, "")
+	}
+}
+
+// loadRunState prepares the state of the current run. When resuming, the environment of the previous run is restored
+// and all task commands that have already succeeded are marked to be skipped.
+func (executor *Executor) loadRunState() {
+	executor.state = newRunState(executor.config.Cli.YamlPath)
+	executor.assignStateKeys()
+
+	if !executor.config.Cli.Resume {
+		return
+	}
+
+	if !utils.DoesFileExist(executor.config.RunStatePath) {
+		log.LogToMain("no previous run state found, running all tasks", log.StyleMajor)
+		return
+	}
+
+	previous := newRunState("")
+	err := utils.Load(executor.config.RunStatePath, previous)
+	if err != nil {
+		log.LogToMain(fmt.Sprintf("unable to load run state, running all tasks: %v", err), log.StyleError)
+		return
+	}
+	if previous.YamlPath != executor.config.Cli.YamlPath {
+		log.LogToMain(fmt.Sprintf("previous run state is for '%s', running all tasks", previous.YamlPath), log.StyleMajor)
+		return
+	}
+
+	executor.state = previous
+	for key, value := range previous.Environment {
+		executor.Environment[key] = value
+	}
+
+	if previous.Exports == nil {
+		previous.Exports = make(map[string]map[string]string)
+	}
+
+	// a resumed task command passes on the env vars it has exported in the previous run
+	for _, task := range executor.Tasks {
+		for _, subTask := range append([]*Task{task}, task.Descendants()...) {
+			subTask.resumed = previous.Tasks[subTask.stateKey] == StatusSuccess
+			if subTask.resumed {
+				subTask.resumedEnvironment = previous.Exports[subTask.stateKey]
+			}
+		}
+	}
+}
+
+// recordRunState persists the outcome of the given completed task command along with the env vars exported by the command
+// and all environment variables captured thus far
+func (executor *Executor) recordRunState(task *Task, status TaskStatus) {
+	if executor.state == nil || task.stateKey == "" || task.resumed {
+		return
+	}
+
+	executor.state.Tasks[task.stateKey] = status
+	if exported := task.exportedEnvironment(); len(exported) > 0 {
+		executor.state.Exports[task.stateKey] = exported
+	}
+	for key, value := range executor.Environment {
+		executor.state.Environment[key] = value
+	}
+
+	err := utils.Save(executor.config.RunStatePath, executor.state)
+	if err != nil {
+		log.LogToMain(fmt.Sprintf("unable to save run state: %v", err), log.StyleError)
+	}
+}
+
+// clearRunState removes the persisted run state, there is nothing to resume after a successful run
+func (executor *Executor) clearRunState() {
+	if utils.DoesFileExist(executor.config.RunStatePath) {
+		os.Remove(executor.config.RunStatePath)
+	}
+}
@@ -22,13 +22,19 @@ package runtime
 
 import (
 	"bufio"
+	"bytes"
 	"fmt"
 	"io"
 	"io/ioutil"
 	"math"
+	"os"
 	"os/exec"
+	"path"
+	"regexp"
+	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"syscall"
 	"time"
 
@@ -42,16 +48,71 @@ import (
 // todo: remove these global vars
 var (
 	sudoPassword string
-	exitSignaled bool
+
+	// exitSignaled indicates that no further commands should be started (1) or not (0), which is read and written by
+	// both the Executor and the goroutines running Task commands (see 'isExitSignaled' and 'signalExit')
+	exitSignaled int32
 )
 
+// isExitSignaled indicates that the run has been halted (no further commands should be started)
+func isExitSignaled() bool {
+	return atomic.LoadInt32(&exitSignaled) == 1
+}
+
+// signalExit halts (or resumes) starting further commands
+func signalExit(signaled bool) {
+	var value int32
+	if signaled {
+		value = 1
+	}
+	atomic.StoreInt32(&exitSignaled, value)
+}
+
 const (
 	StatusRunning TaskStatus = iota
 	StatusPending
 	StatusSuccess
 	StatusError
+	StatusTimedOut
+	StatusSkipped
+	StatusUpToDate
+)
+
+// shellEnvironment is the set of env vars maintained by the child shell itself (which are never passed on to future Tasks)
+var shellEnvironment = map[string]bool{"_": true, "PWD": true, "OLDPWD": true, "SHLVL": true, "BASHFUL_RC": true}
+
+// envNamePattern matches a valid env var name
+var envNamePattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+const (
+	// HookOnFailure marks Tasks that are run after all other Tasks only when a Task has failed (or the run was halted)
+	HookOnFailure = "on-failure"
+
+	// HookFinally marks Tasks that are always run after all other Tasks
+	HookFinally = "finally"
 )
 
+// String returns a short human readable name of the status
+func (status TaskStatus) String() string {
+	switch status {
+	case StatusRunning:
+		return "running"
+	case StatusPending:
+		return "pending"
+	case StatusSuccess:
+		return "success"
+	case StatusError:
+		return "error"
+	case StatusTimedOut:
+		return "timed-out"
+	case StatusSkipped:
+		return "skipped"
+	case StatusUpToDate:
+		return "up-to-date"
+	}
+	return "unknown"
+}
+
 // NewTask creates a new task in the context of the user configuration at a particular screen location (row)
 func NewTask(taskConfig config.TaskConfig, runtimeOptions *config.Options) *Task {
 	task := Task{
@@ -61,20 +122,67 @@ func NewTask(taskConfig config.TaskConfig, runtimeOptions *config.Options) *Task
 	}
 
 	task.Command = newCommand(task.Config)
+	task.compileOutputPatterns()
 
 	task.events = make(chan TaskEvent)
 	task.Status = StatusPending
 
-	for subIndex := range taskConfig.ParallelTasks {
-		subTaskConfig := &taskConfig.ParallelTasks[subIndex]
+	subTaskConfigs := taskConfig.ParallelTasks
+	if len(taskConfig.TaskConfigs) > 0 {
+		subTaskConfigs = taskConfig.TaskConfigs
+		task.sequential = true
+	}
+	if len(taskConfig.Steps) > 0 {
+		// each step is run as a child task of its own (but shown as part of this task)
+		subTaskConfigs = taskConfig.Steps
+		task.sequential = true
+	}
+
+	for subIndex := range subTaskConfigs {
+		subTaskConfig := &subTaskConfigs[subIndex]
 
 		subTask := NewTask(*subTaskConfig, runtimeOptions)
+		subTask.parent = &task
 		task.Children = append(task.Children, subTask)
 	}
 
 	return &task
 }
 
+// compileOutputPatterns compiles the 'expect-stdout' and 'fail-on-output' patterns once (both have already been
+// validated with the config)
+func (task *Task) compileOutputPatterns() {
+	if task.Config.ExpectStdout != "" {
+		task.expectStdout, _ = config.CompileOutputPattern(task.Config.ExpectStdout)
+	}
+	if task.Config.FailOnOutput != "" {
+		task.failOnOutput, _ = config.CompileOutputPattern(task.Config.FailOnOutput)
+	}
+}
+
+// Parent returns the Task which this Task is a child of (nil for top-level Tasks)
+func (task *Task) Parent() *Task {
+	return task.parent
+}
+
+// root returns the top-level Task which this Task is a (nested) child of (or the Task itself if it is a top-level Task)
+func (task *Task) root() *Task {
+	for task.parent != nil {
+		task = task.parent
+	}
+	return task
+}
+
+// Descendants returns all (nested) child Tasks in display order (each child Task followed by its own child Tasks)
+func (task *Task) Descendants() []*Task {
+	var descendants []*Task
+	for _, subTask := range task.Children {
+		descendants = append(descendants, subTask)
+		descendants = append(descendants, subTask.Descendants()...)
+	}
+	return descendants
+}
+
 // UpdateExec reinstantiates the planned command to run based on the given path to an executable
 func (task *Task) UpdateExec(execpath string) {
 	if task.Config.CmdString == "" {
@@ -93,22 +201,93 @@ func (task *Task) UpdateExec(execpath string) {
 
 // Kill will stop any running command (including child Tasks) with a -9 signal
 func (task *Task) Kill() {
-	if task.Config.CmdString != "" && task.Started && !task.Completed {
-		syscall.Kill(-task.Command.Cmd.Process.Pid, syscall.SIGKILL)
+	task.killCommand("")
+	task.stopCondition()
+	for _, subTask := range task.Descendants() {
+		subTask.killCommand("")
+		subTask.stopCondition()
 	}
+}
+
+// stopCondition stops any 'when'/'unless' conditions of the Task being evaluated in the background
+func (task *Task) stopCondition() {
+	task.lock.Lock()
+	defer task.lock.Unlock()
 
+	if task.cancelCondition != nil {
+		task.cancelCondition()
+	}
+}
+
+// killCommand stops the process group of the running Task command with a -9 signal, returning false if the command is
+// not running. When a reason is given, the Task is noted as killed by the user (with the reason as the failure reason).
+func (task *Task) killCommand(reason string) bool {
+	task.lock.Lock()
+	defer task.lock.Unlock()
+
+	if task.Config.CmdString == "" || !task.Started || task.Completed || task.Command.Cmd.Process == nil {
+		return false
+	}
+	if reason != "" {
+		task.killed = true
+		task.Command.FailureReason = reason
+	}
+	syscall.Kill(-task.Command.Cmd.Process.Pid, syscall.SIGKILL)
+	return true
+}
+
+// Killed indicates that the Task command was killed by the user while running
+func (task *Task) Killed() bool {
+	task.lock.Lock()
+	defer task.lock.Unlock()
+	return task.killed
+}
+
+// failureReason returns the failure reason of the current Task command (which may be set by the Executor at any time)
+func (task *Task) failureReason() string {
+	task.lock.Lock()
+	defer task.lock.Unlock()
+	return task.Command.FailureReason
+}
+
+// ErrorOutput returns all stderr lines generated by the Task command
+func (task *Task) ErrorOutput() string {
+	return task.Command.errorBuffer.String()
+}
+
+// hasRemainingCommands indicates if the Task command or any (nested) child Task commands have yet to complete
+func (task *Task) hasRemainingCommands() bool {
+	if task.Config.CmdString != "" && !task.Completed {
+		return true
+	}
 	for _, subTask := range task.Children {
-		if subTask.Config.CmdString != "" && subTask.Started && !subTask.Completed {
-			syscall.Kill(-subTask.Command.Cmd.Process.Pid, syscall.SIGKILL)
+		if subTask.hasRemainingCommands() {
+			return true
 		}
 	}
+	return false
+}
+
+// outcome summarizes the status of a top-level Task and all child Tasks
+func (task *Task) outcome() TaskStatus {
+	switch {
+	case !task.scheduled:
+		return StatusPending
+	case !task.finished:
+		return StatusRunning
+	case task.FailedChildren > 0:
+		return StatusError
+	case task.SkipReason != "" && !task.resumed:
+		return StatusSkipped
+	}
+	return StatusSuccess
 }
 
 func (task *Task) requiresSudoPassword() bool {
 	if task.Config.Sudo && task.Config.CmdString != "" {
 		return true
 	}
-	for _, subTask := range task.Children {
+	for _, subTask := range task.Descendants() {
 		if subTask.Config.Sudo && subTask.Config.CmdString != "" {
 			return true
 		}
@@ -117,7 +296,8 @@ func (task *Task) requiresSudoPassword() bool {
 	return false
 }
 
-// estimateRuntime returns the ETA in seconds until command completion
+// estimateRuntime returns the ETA in seconds until command completion (including all nested child Tasks, where each
+// nested group of Tasks is considered as a single parallel command)
 func (task *Task) estimateRuntime() float64 {
 	var etaSeconds float64
 	// finalize task by appending to the set of final Tasks
@@ -125,6 +305,13 @@ func (task *Task) estimateRuntime() float64 {
 		etaSeconds += task.Command.EstimatedRuntime.Seconds()
 	}
 
+	if task.sequential {
+		for _, subTask := range task.Children {
+			etaSeconds += subTask.estimateRuntime()
+		}
+		return etaSeconds
+	}
+
 	var maxParallelEstimatedRuntime float64
 	var taskEndSecond []float64
 	var currentSecond float64
@@ -132,7 +319,8 @@ func (task *Task) estimateRuntime() float64 {
 
 	for subIndex := range task.Children {
 		subTask := task.Children[subIndex]
-		if subTask.Config.CmdString != "" && subTask.Command.EstimatedRuntime != -1 {
+		subTaskSeconds := subTask.estimateRuntime()
+		if subTaskSeconds > 0 {
 			// this is a sub task with an eta
 			if remainingParallelTasks == 0 {
 
@@ -146,7 +334,7 @@ func (task *Task) estimateRuntime() float64 {
 			}
 
 			// we are still starting Tasks
-			taskEndSecond = append(taskEndSecond, currentSecond+subTask.Command.EstimatedRuntime.Seconds())
+			taskEndSecond = append(taskEndSecond, currentSecond+subTaskSeconds)
 			remainingParallelTasks--
 
 			_, maxEndSecond, err := utils.MinMax(taskEndSecond)
@@ -159,21 +347,241 @@ func (task *Task) estimateRuntime() float64 {
 	return etaSeconds
 }
 
-// run executes a Tasks primary command (not child task commands) and monitors command events
-func (task *Task) Execute(eventChan chan TaskEvent, waiter *sync.WaitGroup, environment map[string]string) {
-
-	task.Command.StartTime = time.Now()
+// retryDelay returns how long to wait before the given (1-based) retry of a failed command
+func (task *Task) retryDelay(retry int) time.Duration {
+	delay := time.Duration(task.Config.RetryDelay * float64(time.Second))
+	if task.Config.RetryBackoff == config.RetryBackoffExponential {
+		delay *= time.Duration(math.Pow(2, float64(retry-1)))
+	}
+	return delay
+}
 
-	eventChan <- TaskEvent{Task: task, Status: StatusRunning, ReturnCode: -1}
+// Execute runs a Tasks primary command (not child task commands), re-running the command on failure if configured to do so, and monitors command events
+func (task *Task) Execute(eventChan chan TaskEvent, waiter *sync.WaitGroup, environment map[string]string) {
 	waiter.Add(1)
 	defer waiter.Done()
 
+	attempts := task.Config.Retries + 1
+	returnCode := task.run(eventChan, environment, 1)
+	succeeded := task.succeeded(returnCode)
+	for attempt := 2; attempt <= attempts && !succeeded && !isExitSignaled() && !task.Killed(); attempt++ {
+		delay := task.retryDelay(attempt - 1)
+		message := fmt.Sprintf("Attempt %d/%d failed (rc:%d), retrying in %v", attempt-1, attempts, returnCode, delay)
+		if reason := task.failureReason(); reason != "" {
+			message = fmt.Sprintf("Attempt %d/%d failed (rc:%d, %s), retrying in %v", attempt-1, attempts, returnCode, reason, delay)
+		}
+		eventChan <- TaskEvent{Task: task, Status: StatusRunning, Stderr: utils.Red(message), ReturnCode: -1, Attempt: attempt - 1, StartTime: task.Command.StartTime, StopTime: task.Command.StopTime}
+		time.Sleep(delay)
+
+		// a command can only be run once, so each attempt gets a fresh process
+		task.lock.Lock()
+		task.Command.reset(task.Config)
+		task.lock.Unlock()
+
+		returnCode = task.run(eventChan, environment, attempt)
+		succeeded = task.succeeded(returnCode)
+	}
+
+	// a command killed by the user always fails (regardless of 'ignore-failure')
+	killed := task.Killed()
+	if !killed && (succeeded || task.Config.IgnoreFailure) {
+		eventChan <- TaskEvent{Task: task, Status: StatusSuccess, Complete: true, ReturnCode: returnCode, Attempt: task.Command.Attempt, StartTime: task.Command.StartTime, StopTime: task.Command.StopTime}
+	} else {
+		status := StatusError
+		if task.Command.TimedOut {
+			status = StatusTimedOut
+		}
+		if task.Config.StopOnFailure && !killed {
+			signalExit(true)
+		}
+		eventChan <- TaskEvent{Task: task, Status: status, Complete: true, ReturnCode: returnCode, Attempt: task.Command.Attempt, StartTime: task.Command.StartTime, StopTime: task.Command.StopTime}
+	}
+}
+
+// isSuccessCode indicates if the given command return code is one of the configured 'success-codes' (only 0 by default)
+func (task *Task) isSuccessCode(returnCode int) bool {
+	if len(task.Config.SuccessCodes) == 0 {
+		return returnCode == 0
+	}
+	for _, code := range task.Config.SuccessCodes {
+		if returnCode == code {
+			return true
+		}
+	}
+	return false
+}
+
+// succeeded indicates if the last command run is considered successful: the command must not have been killed or timed
+// out, must exit with a success code, and the captured output must satisfy the 'expect-stdout' and 'fail-on-output'
+// assertions. The reason of any failed assertion is noted as the command failure reason.
+func (task *Task) succeeded(returnCode int) bool {
+	task.lock.Lock()
+	defer task.lock.Unlock()
+
+	if task.killed || task.Command.TimedOut {
+		return false
+	}
+	if !task.isSuccessCode(returnCode) {
+		if len(task.Config.SuccessCodes) > 0 {
+			codes := make([]string, len(task.Config.SuccessCodes))
+			for idx, code := range task.Config.SuccessCodes {
+				codes[idx] = strconv.Itoa(code)
+			}
+			task.Command.FailureReason = fmt.Sprintf("return code %d is not a success code (%s)", returnCode, strings.Join(codes, ", "))
+		}
+		return false
+	}
+
+	if task.expectStdout != nil {
+		if !task.expectStdout.Match(task.Command.outputBuffer.Bytes()) {
+			task.Command.FailureReason = fmt.Sprintf("stdout did not match expect-stdout '%s'", task.Config.ExpectStdout)
+			return false
+		}
+	}
+	if task.failOnOutput != nil {
+		if task.failOnOutput.Match(task.Command.outputBuffer.Bytes()) || task.failOnOutput.Match(task.Command.errorBuffer.Bytes()) {
+			task.Command.FailureReason = fmt.Sprintf("output matched fail-on-output '%s'", task.Config.FailOnOutput)
+			return false
+		}
+	}
+	return true
+}
+
+// exportedEnvironment is the set of env vars captured from the command that may be passed on to future tasks: only env
+// vars that the command has added or changed (except for shell internals like 'PWD'), limited to the 'export-env' patterns
+// (a resumed Task passes on the env vars exported in the previous run instead)
+func (task *Task) exportedEnvironment() map[string]string {
+	if task.resumed {
+		return copyEnvironment(task.resumedEnvironment)
+	}
+
+	given := make(map[string]string, len(task.Command.Cmd.Env))
+	for _, pair := range task.Command.Cmd.Env {
+		fields := strings.SplitN(pair, "=", 2)
+		if len(fields) == 2 {
+			given[fields[0]] = fields[1]
+		}
+	}
+
+	exported := make(map[string]string)
+	for key, value := range task.Command.Environment {
+		if previous, ok := given[key]; ok && previous == value {
+			continue
+		}
+		if shellEnvironment[key] || !task.exportsEnv(key) {
+			continue
+		}
+		exported[key] = value
+	}
+
+	// registered values are always passed on (regardless of the 'export-env' patterns)
+	for key, value := range task.registeredEnvironment() {
+		exported[key] = value
+	}
+	return exported
+}
+
+// registeredEnvironment is the output (and optionally the return code) of the command stored under the 'register'
+// name (empty unless the command has been run)
+func (task *Task) registeredEnvironment() map[string]string {
+	registered := make(map[string]string)
+	if task.Config.Register == "" || task.Command.StartTime.IsZero() {
+		return registered
+	}
+	registered[task.Config.Register] = strings.TrimSpace(task.Command.outputBuffer.String())
+	if task.Config.RegisterReturnCode {
+		registered[task.Config.Register+"_RC"] = strconv.Itoa(task.Command.ReturnCode)
+	}
+	return registered
+}
+
+// exportsEnv indicates if the given env var name matches any 'export-env' pattern (all names match when no patterns are configured)
+func (task *Task) exportsEnv(name string) bool {
+	if task.Config.ExportEnv == nil {
+		return true
+	}
+	for _, pattern := range task.Config.ExportEnv {
+		if matched, _ := path.Match(pattern, name); matched {
+			return true
+		}
+	}
+	return false
+}
+
+// parseEnvironment parses the env vars written by the child shell, which are NUL delimited 'KEY=value' pairs when
+// supported by 'env', otherwise newline delimited (where a line that does not start with 'KEY=' continues the previous value)
+func parseEnvironment(data []byte) map[string]string {
+	environment := make(map[string]string)
+	if bytes.IndexByte(data, 0) >= 0 {
+		for _, pair := range strings.Split(string(data), "\x00") {
+			fields := strings.SplitN(pair, "=", 2)
+			if len(fields) == 2 && fields[0] != "" {
+				environment[fields[0]] = fields[1]
+			}
+		}
+		return environment
+	}
+
+	previous := ""
+	for _, line := range strings.Split(strings.TrimSuffix(string(data), "\n"), "\n") {
+		fields := strings.SplitN(line, "=", 2)
+		if len(fields) == 2 && envNamePattern.MatchString(fields[0]) {
+			previous = fields[0]
+			environment[previous] = fields[1]
+		} else if previous != "" {
+			environment[previous] += "\n" + line
+		}
+	}
+	return environment
+}
+
+// watchTimeout terminates the command process group if the command is still running after the configured timeout: first
+// with a SIGTERM, then with a SIGKILL if the command has not exited within the grace period. Closing the given exited
+// channel indicates that the command has exited, while the returned channel is closed once the timeout has elapsed.
+func (task *Task) watchTimeout(exited chan struct{}) chan struct{} {
+	timedOut := make(chan struct{})
+	if task.Config.Timeout <= 0 || task.Command.Cmd.Process == nil {
+		return timedOut
+	}
+	timeout := time.Duration(task.Config.Timeout * float64(time.Second))
+	gracePeriod := time.Duration(task.Config.KillGracePeriod * float64(time.Second))
+	pid := task.Command.Cmd.Process.Pid
+
+	go func() {
+		select {
+		case <-exited:
+			return
+		case <-time.After(timeout):
+		}
+		close(timedOut)
+
+		syscall.Kill(-pid, syscall.SIGTERM)
+		select {
+		case <-exited:
+		case <-time.After(gracePeriod):
+			syscall.Kill(-pid, syscall.SIGKILL)
+		}
+	}()
+	return timedOut
+}
+
+// run executes a single attempt of the Tasks primary command, forwarding all stdout/stderr as events, and returns the command return code
+func (task *Task) run(eventChan chan TaskEvent, environment map[string]string, attempt int) int {
+
+	startTime := time.Now()
+	task.lock.Lock()
+	task.Command.StartTime = startTime
+	task.Command.Attempt = attempt
+	task.lock.Unlock()
+
+	eventChan <- TaskEvent{Task: task, Status: StatusRunning, ReturnCode: -1, Attempt: attempt, StartTime: startTime}
+
 	stdoutChan := make(chan string, 1000)
 	stderrChan := make(chan string, 1000)
 	stdoutPipe, _ := task.Command.Cmd.StdoutPipe()
 	stderrPipe, _ := task.Command.Cmd.StderrPipe()
 
-	readPipe := func(resultChan chan string, pipe io.ReadCloser) {
+	readPipe := func(resultChan chan string, pipe io.Reader) {
 		defer close(resultChan)
 
 		scanner := bufio.NewScanner(pipe)
@@ -184,15 +592,44 @@ func (task *Task) Execute(eventChan chan TaskEvent, waiter *sync.WaitGroup, envi
 		}
 	}
 
-	go readPipe(stdoutChan, stdoutPipe)
+	// a registered or asserted output is kept as is (the output lines may be split or cleaned)
+	var stdoutReader io.Reader = stdoutPipe
+	if task.Config.Register != "" || task.Config.ExpectStdout != "" || task.Config.FailOnOutput != "" {
+		stdoutReader = io.TeeReader(stdoutPipe, task.Command.outputBuffer)
+	}
+
+	go readPipe(stdoutChan, stdoutReader)
 	go readPipe(stderrChan, stderrPipe)
 
-	// copy env vars into proc
+	// copy env vars into proc: the bashful environment, then any env vars passed on from previous tasks, then the
+	// configured env vars of the task (later values take precedence)
+	task.Command.Cmd.Env = os.Environ()
 	for k, v := range environment {
 		task.Command.Cmd.Env = append(task.Command.Cmd.Env, fmt.Sprintf("%s=%s", k, v))
 	}
+	for k, v := range task.Config.Env {
+		task.Command.Cmd.Env = append(task.Command.Cmd.Env, fmt.Sprintf("%s=%s", k, v))
+	}
 
+	// the process is started while holding the lock, since the Executor may kill the process at any time
+	task.lock.Lock()
 	task.Command.Cmd.Start()
+	task.lock.Unlock()
+
+	// the child shell has its own copy of the write end of the env pipe, so the pipe is read until the child shell exits
+	// (reading while the command runs ensures that a large environment cannot fill the pipe and block the child shell)
+	task.Command.Cmd.ExtraFiles[0].Close()
+	envData := make(chan []byte, 1)
+	go func() {
+		data, err := ioutil.ReadAll(task.Command.EnvReadFile)
+		utils.CheckError(err, "Could not read env vars from child shell")
+		task.Command.EnvReadFile.Close()
+		envData <- data
+	}()
+
+	exited := make(chan struct{})
+	defer close(exited)
+	timedOut := task.watchTimeout(exited)
 
 	for {
 		select {
@@ -206,7 +643,7 @@ func (task *Task) Execute(eventChan chan TaskEvent, waiter *sync.WaitGroup, envi
 				// todo: we should always throw the TaskEvent? let the TaskEvent handler deal with TaskEvent/polling...
 				if task.Config.EventDriven {
 					// this is TaskEvent driven... (signal this TaskEvent)
-					eventChan <- TaskEvent{Task: task, Status: StatusRunning, Stdout: utils.Blue(stdoutMsg), ReturnCode: -1}
+					eventChan <- TaskEvent{Task: task, Status: StatusRunning, Stdout: utils.Blue(stdoutMsg), ReturnCode: -1, Attempt: attempt, StartTime: startTime}
 				}
 				// else {
 				// 	// on a polling interval... (do not create an TaskEvent)
@@ -222,7 +659,7 @@ func (task *Task) Execute(eventChan chan TaskEvent, waiter *sync.WaitGroup, envi
 				// todo: we should always throw the TaskEvent? let the TaskEvent handler deal with TaskEvent/polling...
 				if task.Config.EventDriven {
 					// either this is TaskEvent driven... (signal this TaskEvent)
-					eventChan <- TaskEvent{Task: task, Status: StatusRunning, Stderr: utils.Red(stderrMsg), ReturnCode: -1}
+					eventChan <- TaskEvent{Task: task, Status: StatusRunning, Stderr: utils.Red(stderrMsg), ReturnCode: -1, Attempt: attempt, StartTime: startTime}
 				}
 				// else {
 				// 	// or on a polling interval... (do not create an TaskEvent)
@@ -249,38 +686,29 @@ func (task *Task) Execute(eventChan chan TaskEvent, waiter *sync.WaitGroup, envi
 		} else {
 			returnCode = -1
 			returnCodeMsg = "Failed to run: " + err.Error()
-			eventChan <- TaskEvent{Task: task, Status: StatusError, Stderr: returnCodeMsg, ReturnCode: returnCode}
+			eventChan <- TaskEvent{Task: task, Status: StatusError, Stderr: returnCodeMsg, ReturnCode: returnCode, Attempt: attempt, StartTime: startTime}
 			task.Command.errorBuffer.WriteString(returnCodeMsg + "\n")
 		}
 	}
+	task.lock.Lock()
 	task.Command.ReturnCode = returnCode
 	task.Command.StopTime = time.Now()
-
-	// close the write end of the pipe since the child shell is positively no longer writing to it
-	task.Command.Cmd.ExtraFiles[0].Close()
-	data, err := ioutil.ReadAll(task.Command.EnvReadFile)
-	utils.CheckError(err, "Could not read env vars from child shell")
-
-	if environment != nil {
-		lines := strings.Split(string(data[:]), "\n")
-		for _, line := range lines {
-			fields := strings.SplitN(strings.TrimSpace(line), "=", 2)
-			if len(fields) == 2 {
-				environment[fields[0]] = fields[1]
-			} else if len(fields) == 1 {
-				environment[fields[0]] = ""
-			}
-		}
+	task.lock.Unlock()
+
+	select {
+	case <-timedOut:
+		reason := fmt.Sprintf("timed out after %v", time.Duration(task.Config.Timeout*float64(time.Second)))
+		task.lock.Lock()
+		task.Command.TimedOut = true
+		task.Command.FailureReason = reason
+		task.lock.Unlock()
+		task.Command.errorBuffer.WriteString("Terminated: " + reason + "\n")
+	default:
 	}
 
-	if returnCode == 0 || task.Config.IgnoreFailure {
-		eventChan <- TaskEvent{Task: task, Status: StatusSuccess, Complete: true, ReturnCode: returnCode}
-	} else {
-		eventChan <- TaskEvent{Task: task, Status: StatusError, Complete: true, ReturnCode: returnCode}
-		if task.Config.StopOnFailure {
-			exitSignaled = true
-		}
-	}
+	task.Command.Environment = parseEnvironment(<-envData)
+
+	return returnCode
 }
 
 // variableSplitFunc splits a bytestream based on either newline characters or by length (if the string is too long)
@@ -3,6 +3,7 @@ package runtime
 import (
 	"github.com/lunixbochs/vtclean"
 	"github.com/wagoodman/bashful/pkg/config"
+	"reflect"
 	"strings"
 	"testing"
 	"time"
@@ -56,6 +57,21 @@ tasks:
       - cmd: ./do/thing.sh 3
       - cmd: ./do/thing.sh 4`),
 		},
+
+		"nested task groups": {
+			index:         0,
+			maxParallel:   4,
+			parentTaskEta: 0,
+			childTaskEta:  []int{20, 0, 10, 30},
+			expectedEta:   40,
+			runYaml: []byte(`
+tasks:
+  - parallel-tasks:
+      - cmd: ./do/thing.sh 2
+      - tasks:
+          - cmd: ./do/thing.sh 1
+          - cmd: ./do/thing.sh 3`),
+		},
 	}
 
 	for name, testCase := range table {
@@ -69,7 +85,7 @@ tasks:
 		client.Config.Options.MaxParallelCmds = testCase.maxParallel
 		for _, task := range client.Executor.Tasks {
 			task.Command.addEstimatedRuntime(time.Duration(testCase.parentTaskEta) * time.Second)
-			for cIdx, subTask := range task.Children {
+			for cIdx, subTask := range task.Descendants() {
 				subTask.Command.addEstimatedRuntime(time.Duration(testCase.childTaskEta[cIdx]) * time.Second)
 			}
 		}
@@ -84,6 +100,108 @@ tasks:
 	}
 }
 
+func Test_Task_retryDelay(t *testing.T) {
+	table := map[string]struct {
+		backoff       string
+		delay         float64
+		retry         int
+		expectedDelay time.Duration
+	}{
+		"default backoff":       {backoff: "", delay: 2, retry: 3, expectedDelay: 2 * time.Second},
+		"constant backoff":      {backoff: config.RetryBackoffConstant, delay: 0.5, retry: 2, expectedDelay: 500 * time.Millisecond},
+		"exponential backoff":   {backoff: config.RetryBackoffExponential, delay: 2, retry: 3, expectedDelay: 8 * time.Second},
+		"exponential first try": {backoff: config.RetryBackoffExponential, delay: 2, retry: 1, expectedDelay: 2 * time.Second},
+	}
+
+	for name, testCase := range table {
+		t.Logf("Running test case: %s", name)
+		task := NewTask(config.TaskConfig{CmdString: "true", RetryBackoff: testCase.backoff, RetryDelay: testCase.delay}, nil)
+
+		delay := task.retryDelay(testCase.retry)
+		if delay != testCase.expectedDelay {
+			t.Errorf("   expected delay='%v', got '%v'", testCase.expectedDelay, delay)
+		}
+	}
+}
+
+func Test_Task_succeeded(t *testing.T) {
+	table := map[string]struct {
+		taskConfig     config.TaskConfig
+		returnCode     int
+		stdout         string
+		stderr         string
+		expected       bool
+		expectedReason string
+	}{
+		"default success code":    {taskConfig: config.TaskConfig{}, returnCode: 0, expected: true},
+		"default failure code":    {taskConfig: config.TaskConfig{}, returnCode: 1, expected: false},
+		"configured success code": {taskConfig: config.TaskConfig{SuccessCodes: []int{0, 1}}, returnCode: 1, expected: true},
+		"configured failure code": {taskConfig: config.TaskConfig{SuccessCodes: []int{0, 1}}, returnCode: 2, expected: false, expectedReason: "return code 2 is not a success code (0, 1)"},
+		"expected stdout":         {taskConfig: config.TaskConfig{ExpectStdout: "^ok$"}, stdout: "checking\nok\n", expected: true},
+		"unexpected stdout":       {taskConfig: config.TaskConfig{ExpectStdout: "^ok$"}, stdout: "checking\nnot ok\n", expected: false, expectedReason: "stdout did not match expect-stdout '^ok$'"},
+		"failing stdout":          {taskConfig: config.TaskConfig{FailOnOutput: "(?i)error"}, stdout: "Error: missing file\n", expected: false, expectedReason: "output matched fail-on-output '(?i)error'"},
+		"failing stderr":          {taskConfig: config.TaskConfig{FailOnOutput: "(?i)error"}, stderr: "error: missing file\n", expected: false, expectedReason: "output matched fail-on-output '(?i)error'"},
+		"no failing output":       {taskConfig: config.TaskConfig{FailOnOutput: "(?i)error"}, stdout: "done\n", expected: true},
+	}
+
+	for name, testCase := range table {
+		t.Logf("Running test case: %s", name)
+		testCase.taskConfig.CmdString = "true"
+		task := NewTask(testCase.taskConfig, nil)
+		task.Command.outputBuffer.WriteString(testCase.stdout)
+		task.Command.errorBuffer.WriteString(testCase.stderr)
+
+		if succeeded := task.succeeded(testCase.returnCode); succeeded != testCase.expected {
+			t.Errorf("   expected succeeded=%v, got %v", testCase.expected, succeeded)
+		}
+		if task.Command.FailureReason != testCase.expectedReason {
+			t.Errorf("   expected reason='%s', got '%s'", testCase.expectedReason, task.Command.FailureReason)
+		}
+	}
+}
+
+func Test_parseEnvironment(t *testing.T) {
+	table := map[string]struct {
+		data        string
+		expectedEnv map[string]string
+	}{
+		"nul delimited":         {data: "A=1\x00B=two\nlines\x00C=\x00D=x=y\x00", expectedEnv: map[string]string{"A": "1", "B": "two\nlines", "C": "", "D": "x=y"}},
+		"newline delimited":     {data: "A=1\nB=two\nlines\nC= spaced \n", expectedEnv: map[string]string{"A": "1", "B": "two\nlines", "C": " spaced "}},
+		"continued empty lines": {data: "A=x\n\ny\n", expectedEnv: map[string]string{"A": "x\n\ny"}},
+	}
+
+	for name, testCase := range table {
+		t.Logf("Running test case: %s", name)
+		env := parseEnvironment([]byte(testCase.data))
+		if !reflect.DeepEqual(env, testCase.expectedEnv) {
+			t.Errorf("   expected env=%q, got %q", testCase.expectedEnv, env)
+		}
+	}
+}
+
+func Test_Task_exportedEnvironment(t *testing.T) {
+	table := map[string]struct {
+		exportEnv   []string
+		expectedEnv map[string]string
+	}{
+		"all changed env vars": {exportEnv: nil, expectedEnv: map[string]string{"ADDED": "1", "CHANGED": "new", "API_TOKEN": "x"}},
+		"matching env vars":    {exportEnv: []string{"API_*", "ADDED"}, expectedEnv: map[string]string{"ADDED": "1", "API_TOKEN": "x"}},
+		"no env vars":          {exportEnv: []string{}, expectedEnv: map[string]string{}},
+	}
+
+	for name, testCase := range table {
+		t.Logf("Running test case: %s", name)
+		task := NewTask(config.TaskConfig{CmdString: "true", ExportEnv: testCase.exportEnv}, nil)
+		task.Command.Cmd.Env = []string{"SAME=1", "CHANGED=old", "PWD=/"}
+		task.Command.Environment = map[string]string{"SAME": "1", "CHANGED": "new", "ADDED": "1", "API_TOKEN": "x", "PWD": "/tmp", "SHLVL": "2"}
+
+		env := task.exportedEnvironment()
+		if !reflect.DeepEqual(env, testCase.expectedEnv) {
+			t.Errorf("   expected env=%v, got %v", testCase.expectedEnv, env)
+		}
+	}
+}
+
 func Test_Task_UpdateExec(t *testing.T) {
 	runYaml := []byte(`
 tasks:
@@ -220,8 +338,8 @@ func Test_Task_Execute(t *testing.T) {
 				CmdString: "true",
 			},
 			expectedEvents: []TaskEvent{
-				{nil, StatusRunning, "", "", false, -1},
-				{nil, StatusSuccess, "", "", true, 0},
+				{Status: StatusRunning, ReturnCode: -1},
+				{Status: StatusSuccess, Complete: true, ReturnCode: 0},
 			},
 			expectedEnv: map[string]string{},
 		},
@@ -231,8 +349,8 @@ func Test_Task_Execute(t *testing.T) {
 				CmdString: "false",
 			},
 			expectedEvents: []TaskEvent{
-				{nil, StatusRunning, "", "", false, -1},
-				{nil, StatusError, "", "", true, 1},
+				{Status: StatusRunning, ReturnCode: -1},
+				{Status: StatusError, Complete: true, ReturnCode: 1},
 			},
 			expectedEnv: map[string]string{},
 		},
@@ -243,8 +361,8 @@ func Test_Task_Execute(t *testing.T) {
 				IgnoreFailure: true,
 			},
 			expectedEvents: []TaskEvent{
-				{nil, StatusRunning, "", "", false, -1},
-				{nil, StatusSuccess, "", "", true, 1},
+				{Status: StatusRunning, ReturnCode: -1},
+				{Status: StatusSuccess, Complete: true, ReturnCode: 1},
 			},
 			expectedEnv: map[string]string{},
 		},
@@ -255,8 +373,8 @@ func Test_Task_Execute(t *testing.T) {
 				IgnoreFailure: true,
 			},
 			expectedEvents: []TaskEvent{
-				{nil, StatusRunning, "", "", false, -1},
-				{nil, StatusSuccess, "", "", true, 0},
+				{Status: StatusRunning, ReturnCode: -1},
+				{Status: StatusSuccess, Complete: true, ReturnCode: 0},
 			},
 			expectedEnv: map[string]string{
 				"INITIAL_TEST_DATA": "ALSO42",
@@ -270,9 +388,50 @@ func Test_Task_Execute(t *testing.T) {
 				EventDriven: true,
 			},
 			expectedEvents: []TaskEvent{
-				{nil, StatusRunning, "", "", false, -1},
-				{nil, StatusRunning, "sup", "", false, -1},
-				{nil, StatusSuccess, "", "", true, 0},
+				{Status: StatusRunning, ReturnCode: -1},
+				{Status: StatusRunning, Stdout: "sup", ReturnCode: -1},
+				{Status: StatusSuccess, Complete: true, ReturnCode: 0},
+			},
+			expectedEnv: map[string]string{},
+		},
+		"single task (retry failure)": {
+			taskConfig: config.TaskConfig{
+				Name:        "easy task",
+				CmdString:   "false",
+				EventDriven: true,
+				Retries:     1,
+			},
+			expectedEvents: []TaskEvent{
+				{Status: StatusRunning, ReturnCode: -1, Attempt: 1},
+				{Status: StatusRunning, Stderr: "Attempt 1/2 failed (rc:1), retrying in 0s", ReturnCode: -1, Attempt: 1},
+				{Status: StatusRunning, ReturnCode: -1, Attempt: 2},
+				{Status: StatusError, Complete: true, ReturnCode: 1, Attempt: 2},
+			},
+			expectedEnv: map[string]string{},
+		},
+		"single task (timeout)": {
+			taskConfig: config.TaskConfig{
+				Name:            "easy task",
+				CmdString:       "sleep 10",
+				Timeout:         0.1,
+				KillGracePeriod: 5,
+			},
+			expectedEvents: []TaskEvent{
+				{Status: StatusRunning, ReturnCode: -1},
+				{Status: StatusTimedOut, Complete: true, ReturnCode: -1},
+			},
+			expectedEnv: map[string]string{},
+		},
+		"single task (timeout, ignore SIGTERM)": {
+			taskConfig: config.TaskConfig{
+				Name:            "easy task",
+				CmdString:       "trap '' TERM; sleep 10",
+				Timeout:         0.1,
+				KillGracePeriod: 0.1,
+			},
+			expectedEvents: []TaskEvent{
+				{Status: StatusRunning, ReturnCode: -1},
+				{Status: StatusTimedOut, Complete: true, ReturnCode: -1},
 			},
 			expectedEnv: map[string]string{},
 		},
@@ -283,9 +442,9 @@ func Test_Task_Execute(t *testing.T) {
 				EventDriven: true,
 			},
 			expectedEvents: []TaskEvent{
-				{nil, StatusRunning, "", "", false, -1},
-				{nil, StatusRunning, "", "meh", false, -1},
-				{nil, StatusSuccess, "", "", true, 0},
+				{Status: StatusRunning, ReturnCode: -1},
+				{Status: StatusRunning, Stderr: "meh", ReturnCode: -1},
+				{Status: StatusSuccess, Complete: true, ReturnCode: 0},
 			},
 			expectedEnv: map[string]string{},
 		},
@@ -331,7 +490,22 @@ func Test_Task_Execute(t *testing.T) {
 			if expEvent.Stdout != vtclean.Clean(actualEvent.Stdout, false) {
 				t.Errorf("   event %d: expected stdout='%v', got '%v'", idx, expEvent.Stdout, actualEvent.Stdout)
 			}
+			if expEvent.Attempt != 0 && expEvent.Attempt != actualEvent.Attempt {
+				t.Errorf("   event %d: expected attempt=%v, got %v", idx, expEvent.Attempt, actualEvent.Attempt)
+			}
+			// the start/stop time of each attempt is given with the event (the task command changes with every attempt)
+			if actualEvent.StartTime.IsZero() {
+				t.Errorf("   event %d: expected a start time", idx)
+			}
+			if actualEvent.Complete && actualEvent.StopTime.Before(actualEvent.StartTime) {
+				t.Errorf("   event %d: expected a stop time after %v, got %v", idx, actualEvent.StartTime, actualEvent.StopTime)
+			}
+
+		}
 
+		// the env vars exported by the command are passed on to future tasks by the executor (see 'mergeEnvironment')
+		for key, value := range task.exportedEnvironment() {
+			environment[key] = value
 		}
 
 		// this is not valid, there may be several variables generated, few of which are intentional
@@ -26,6 +26,7 @@ import (
 	"github.com/wagoodman/bashful/pkg/config"
 	"os"
 	"os/exec"
+	"regexp"
 	"sync"
 	"time"
 )
@@ -63,11 +64,41 @@ type Executor struct {
 	// cmdEtaCache is the task CmdString-to-ETASeconds for any previously run command (read from EtaCachePath)
 	cmdEtaCache map[string]time.Duration
 
+	// fingerprintCache is the task key-to-input-fingerprint for any previously succeeded command with inputs or outputs (read from FingerprintCachePath, see 'fingerprintCacheKey')
+	fingerprintCache map[string]string
+
 	// Tasks is a list of all Task objects that will be invoked
 	Tasks []*Task
 
 	// Statistics contains runtime statistics of all planned tasks
 	Statistics *TaskStatistics
+
+	// events is a channel where all raw command events (from all running Tasks and child Tasks) are queued to
+	events chan TaskEvent
+
+	// active is a list of all top-level Tasks that have been scheduled but have not yet finished
+	active []*Task
+
+	// finallyTasks is a list of all Task objects that are always invoked after all Tasks have finished
+	finallyTasks []*Task
+
+	// onFailureTasks is a list of all Task objects that are invoked after all Tasks have finished only if any Task has failed
+	onFailureTasks []*Task
+
+	// interrupted indicates that the user has requested to stop all running Tasks (no further Tasks will be started)
+	interrupted bool
+
+	// state is the outcome of all Tasks thus far, persisted to the RunStatePath after every completed command
+	state *runState
+
+	// controls is a channel where all user requested TaskActions are queued to (see Control)
+	controls chan taskControl
+
+	// conditions is a channel where the outcomes of all task conditions evaluated in the background are queued to
+	conditions chan conditionResult
+
+	// poolUsage is the number of running Task commands in each resource pool (see 'Options.Pools')
+	poolUsage map[string]int
 }
 
 type TaskStatistics struct {
@@ -80,8 +111,20 @@ type TaskStatistics struct {
 	// Completed is a list of Task objects that have been invoked (regardless of the return code value)
 	Completed []*Task
 
+	// Skipped is a list of Task objects that were not run (also found in Completed)
+	Skipped []*Task
+
+	// UpToDate is a list of Task objects that were not run since all inputs are unchanged since the last successful run (also found in Completed)
+	UpToDate []*Task
+
+	// Killed is a list of Task objects that were killed by the user while running (also found in Failed)
+	Killed []*Task
+
 	// Total indicates the number of tasks that can be run (Note: this is not necessarily the same number of tasks planned to be run)
 	Total int
+
+	// Warnings is a list of problems noticed while running that did not fail any Task (e.g. conflicting exported env vars)
+	Warnings []string
 }
 
 // Task is a runtime object derived from the TaskConfig (parsed from the user yaml) and contains everything needed to Execute, track, and display the task.
@@ -96,9 +139,37 @@ type Task struct {
 	// Command represents all non-Config items used to Execute and track task progress
 	Command command
 
-	// Children is a list of all sub-Tasks that should be run concurrently
+	// Children is a list of all sub-Tasks that should be run concurrently (or one after another, see 'sequential'). Each
+	// sub-Task may have Children of its own.
 	Children []*Task
 
+	// Hook indicates the cleanup section this Task is run in (HookOnFailure or HookFinally), empty for all regular Tasks
+	Hook string
+
+	// parent is the Task which this Task is a child of (nil for top-level Tasks)
+	parent *Task
+
+	// sequential indicates that the Children are run one after another (see 'tasks' and 'steps') instead of concurrently
+	sequential bool
+
+	// entered indicates that the child Task has been reached by the Executor (and any skip reason has been determined)
+	entered bool
+
+	// conditionPending indicates that the 'when'/'unless' conditions are being evaluated in the background (the Task, and
+	// all child Tasks, may not be started until the result has been received)
+	conditionPending bool
+
+	// cancelCondition stops the background evaluation of the 'when'/'unless' conditions (nil when not evaluating)
+	cancelCondition func()
+
+	// environment is the set of env vars given to the Task command (and to the child Tasks of a parallel group): a snapshot
+	// of the Executor environment taken when the top-level Task was scheduled, along with any env vars exported by the
+	// preceding Tasks of a sequential group (see 'childEnvironment')
+	environment map[string]string
+
+	// dependencies is a list of Tasks that must finish before this Task may be scheduled
+	dependencies []*Task
+
 	// events is a channel where all raw command events are queued to
 	events chan TaskEvent
 
@@ -114,8 +185,52 @@ type Task struct {
 	// Completed indicates whether the Task has been finished execution
 	Completed bool
 
-	// FailedChildren is a list of Tasks with a non-zero return value
+	// scheduled indicates whether the Task (and all child Tasks) has been handed to the Executor to be run
+	scheduled bool
+
+	// finished indicates whether the Task and all child Tasks have been finished execution
+	finished bool
+
+	// FailedChildren is the number of failed commands of this Task and all (nested) child Tasks
 	FailedChildren int
+
+	// SkipReason indicates why the Task was not run (empty unless the Task was skipped)
+	SkipReason string
+
+	// blocked indicates the Task was skipped since a Task it needs did not succeed
+	blocked bool
+
+	// stateKey identifies the Task command within the persisted run state (empty for Tasks without a command)
+	stateKey string
+
+	// resumed indicates the Task command already succeeded in a previous run and is not run again
+	resumed bool
+
+	// resumedEnvironment is the set of env vars exported by the Task command in the previous run (only when resumed)
+	resumedEnvironment map[string]string
+
+	// fingerprint is the hash of all Task inputs taken before the command was started (empty for Tasks without inputs or outputs)
+	fingerprint string
+
+	// expectStdout and failOnOutput are the compiled 'expect-stdout' and 'fail-on-output' patterns (nil when not configured)
+	expectStdout *regexp.Regexp
+	failOnOutput *regexp.Regexp
+
+	// fingerprintKey is the key of the Task in the fingerprint cache, taken before the command was started (empty for Tasks without inputs or outputs)
+	fingerprintKey string
+
+	// killed indicates the Task command was killed by the user (the command is not retried and the run is not halted)
+	killed bool
+
+	// lock guards the command process, the killed state, and the command failure reason, which are shared between the
+	// Executor (see 'onControl') and the goroutine running the Task command
+	lock sync.Mutex
+
+	// WaitingOnPool is the name of the resource pool the Task command is waiting on to be started (empty unless the pool is full)
+	WaitingOnPool string
+
+	// heldPool is the name of the resource pool the running Task command has a slot in (empty when not running in a pool)
+	heldPool string
 }
 
 // command represents all non-Config items used to Execute and track task progress
@@ -138,6 +253,15 @@ type command struct {
 	// ReturnCode is simply the value returned from the child process after Cmd execution
 	ReturnCode int
 
+	// Attempt is the (1-based) number of times the Cmd has been run (more than once only when the task is configured with retries)
+	Attempt int
+
+	// TimedOut indicates that the Cmd was terminated since it ran longer than the configured timeout
+	TimedOut bool
+
+	// FailureReason is a short description of why the command is considered failed beyond a non-zero return code (optional)
+	FailureReason string
+
 	// EnvReadFile is an extra pipe given to the child shell process for exfiltrating env vars back up to bashful (to provide as input for future Tasks)
 	EnvReadFile *os.File
 
@@ -146,6 +270,9 @@ type command struct {
 
 	// errorBuffer contains all stderr lines generated from the executed command (used to generate the task report)
 	errorBuffer *bytes.Buffer
+
+	// outputBuffer contains all stdout generated from the executed command (only when the output is registered or asserted, see 'Register', 'ExpectStdout', and 'FailOnOutput')
+	outputBuffer *bytes.Buffer
 }
 
 // TaskStatus represents whether a task command is about to run, already running, or has completed (in which case, was it successful or not)
@@ -171,4 +298,12 @@ type TaskEvent struct {
 	// todo: remove return code from an event
 	// ReturnCode is the sub-process return code value upon completion
 	ReturnCode int
+
+	// Attempt is the (1-based) number of times the task command has been run when this event was generated
+	Attempt int
+
+	// StartTime and StopTime are when the attempt of the command was started and has exited (zero when not started or
+	// still running). Handlers should read these instead of the Task command, which is changed by every attempt.
+	StartTime time.Time
+	StopTime  time.Time
 }
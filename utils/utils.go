@@ -104,8 +104,20 @@ func CheckError(err error, message string) {
 	}
 }
 
+// exitHooks is a list of functions invoked before exiting
+var exitHooks []func()
+
+// OnExit registers a function to be invoked before exiting with Exit or ExitWithErrorMessage (e.g. to restore the terminal state)
+func OnExit(hook func()) {
+	exitHooks = append(exitHooks, hook)
+}
+
 // TODO: THIS NEEDS TO BE RETHOUGHT
 func cleanup() {
+	for _, hook := range exitHooks {
+		hook()
+	}
+
 	// // stop any running tasks
 	// for _, task := range AllTasks {
 	// 	task.Kill()